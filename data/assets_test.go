@@ -0,0 +1,31 @@
+package data
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestAssetsUpToDate fails when a file of static/ was changed without
+// regenerating the assets with mage assets.
+func TestAssetsUpToDate(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "static", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		want, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Asset(filepath.Base(name))
+		if err != nil {
+			t.Errorf("%s is not embedded, run mage assets", name)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the embedded asset, run mage assets", name)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package format implements the fmt command, which rewrites present files in
+// their canonical format.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/urfave/cli"
+)
+
+// Command returns the fmt command.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "fmt",
+		Usage:     "format .slide and .article files",
+		ArgsUsage: "<dir or file>...",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "w",
+				Usage: "write the result to the source file instead of stdout",
+			},
+			cli.BoolFlag{
+				Name:  "d",
+				Usage: "display diffs instead of rewriting files",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			paths := []string(ctx.Args())
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+			return Format(os.Stdout, Options{
+				Write: ctx.Bool("w"),
+				Diff:  ctx.Bool("d"),
+			}, paths...)
+		},
+	}
+}
+
+// Options control what Format does with the formatted documents.
+type Options struct {
+	// Write the formatted document back to its file.
+	Write bool
+
+	// Diff writes a diff between the file and its formatted version.
+	Diff bool
+}
+
+// Format formats every .slide and .article file under paths, which can be
+// directories or files. By default the formatted documents are written to w.
+func Format(w io.Writer, opts Options, paths ...string) error {
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if err := formatFile(w, opts, p); err != nil {
+				return err
+			}
+			continue
+		}
+		err = filepath.WalkDir(p, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if name != p && d.Name()[0] == '.' {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isDocument(name) {
+				return nil
+			}
+			return formatFile(w, opts, name)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFile(w io.Writer, opts Options, name string) error {
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	ctx := present.Context{ReadFile: readNothing, NoHighlight: true, TrustHTML: true}
+	doc, err := ctx.Parse(bytes.NewReader(src), name, present.ParseComments|present.AllErrors)
+	if err != nil {
+		return err
+	}
+	if err := syntaxError(doc, name, src); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := present.Format(&buf, doc); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	res := buf.Bytes()
+	if !opts.Write && !opts.Diff {
+		_, err := w.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if opts.Diff {
+		d, err := diff(name, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %v", err)
+		}
+		if _, err := w.Write(d); err != nil {
+			return err
+		}
+	}
+	if opts.Write {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(name, res, info.Mode().Perm())
+	}
+	return nil
+}
+
+// readNothing is the ReadFile of the parser. Included files are not read,
+// directives are printed as written whether they can be parsed or not.
+func readNothing(name string) ([]byte, error) {
+	return nil, nil
+}
+
+// syntaxError returns the first problem of doc that is not in a directive.
+// Problems in directives, like missing files or addresses that don't match,
+// are left to the lint command.
+func syntaxError(doc *models.Doc, name string, src []byte) error {
+	lines := strings.Split(string(src), "\n")
+	for i, d := range doc.Diagnostics {
+		if d.File != name || d.Line < 1 || d.Line > len(lines) || strings.HasPrefix(lines[d.Line-1], ".") {
+			continue
+		}
+		return &doc.Diagnostics[i]
+	}
+	return nil
+}
+
+// diff returns a unified diff of a and b using the diff program, labelled with
+// the file name.
+func diff(name string, a, b []byte) ([]byte, error) {
+	f1, err := writeTemp(a)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+	f2, err := writeTemp(b)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+	out, err := exec.Command("diff", "-u",
+		"--label", name+".orig", "--label", name, f1, f2).CombinedOutput()
+	if len(out) > 0 {
+		// diff exits with status 1 when the files differ.
+		return out, nil
+	}
+	return nil, err
+}
+
+func writeTemp(data []byte) (string, error) {
+	f, err := ioutil.TempFile("", "vectypresent-fmt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func isDocument(name string) bool {
+	switch filepath.Ext(name) {
+	case ".slide", ".article":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,99 @@
+package format
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// formattedDoc is in the canonical format, it includes files that don't
+// exist and code addresses that don't match.
+const formattedDoc = `Title
+Subtitle
+
+Author
+
+* Code
+
+.code missing.go /^func main/,/^}/
+
+	func main() {
+		if true {
+			println("tabs")
+		}
+	}
+
+.html missing.html
+
+* Other
+
+.image gopher.png
+
+.unknown directive
+`
+
+func TestFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectypresent-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.slide")
+	if err := ioutil.WriteFile(name, []byte(formattedDoc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := Format(&out, Options{}, name); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != formattedDoc {
+		t.Errorf("expected the document unchanged got:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := Format(&out, Options{Write: true}, dir); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != formattedDoc || out.Len() != 0 {
+		t.Errorf("expected the file unchanged got:\n%s", b)
+	}
+
+	bad := filepath.Join(dir, "bad.slide")
+	if err := ioutil.WriteFile(bad, []byte("Title\nTheme: Bad Theme\n\n* Section\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Format(&out, Options{}, bad); err == nil || !strings.Contains(err.Error(), "invalid theme") {
+		t.Errorf("expected a syntax error got %v", err)
+	}
+}
+
+func TestFormatRewrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectypresent-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.slide")
+	src := "Title\n\n\n* Section\nSome text\n\n  pre\n  \tindented\n"
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Format(ioutil.Discard, Options{Write: true}, name); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "Title\n\n* Section\n\nSome text\n\n\tpre\n\t\tindented\n"
+	if string(b) != want {
+		t.Errorf("expected %q got %q", want, b)
+	}
+}
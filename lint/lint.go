@@ -0,0 +1,109 @@
+// Package lint implements the lint command, which reports problems in present
+// files.
+package lint
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/urfave/cli"
+)
+
+// Command returns the lint command.
+func Command() cli.Command {
+	return cli.Command{
+		Name:      "lint",
+		Usage:     "report problems in .slide and .article files",
+		ArgsUsage: "<dir or file>...",
+		Action: func(ctx *cli.Context) error {
+			paths := []string(ctx.Args())
+			if len(paths) == 0 {
+				paths = []string{"."}
+			}
+			n, err := Lint(os.Stdout, paths...)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("%d problems found", n)
+			}
+			return nil
+		},
+	}
+}
+
+// Lint checks every .slide and .article file under paths, which can be
+// directories or files, and writes the problems found to w in
+// file:line:col: message form. It returns the number of problems found.
+func Lint(w io.Writer, paths ...string) (int, error) {
+	n := 0
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return n, err
+		}
+		dir, root := p, "."
+		if !info.IsDir() {
+			dir, root = filepath.Dir(p), filepath.Base(p)
+		}
+		fsys := os.DirFS(dir)
+		err = fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if name != root && d.Name()[0] == '.' {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !isDocument(name) {
+				return nil
+			}
+			errs, err := lintFile(fsys, dir, name)
+			if err != nil {
+				return err
+			}
+			for _, e := range errs {
+				e.File = filepath.Join(dir, filepath.FromSlash(e.File))
+				fmt.Fprintln(w, e.Error())
+			}
+			n += len(errs)
+			return nil
+		})
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// lintFile lints the file name in fsys, which is the directory dir. Files
+// included by the document are read relative to dir, so they may live outside
+// of fsys.
+func lintFile(fsys fs.FS, dir, name string) ([]models.Error, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctx := present.Context{NoHighlight: true, ReadFile: func(filename string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, filename))
+	}}
+	return ctx.Lint(f, name)
+}
+
+func isDocument(name string) bool {
+	switch filepath.Ext(name) {
+	case ".slide", ".article":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,52 @@
+package lint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectypresent-lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"good.slide": "Title\n\n* Section\n\n.code main.go /^func main/\n",
+		"bad.slide":  "Title\n\n* Section\n\n.code missing.go\n\n.unknown\n",
+		"main.go":    "package main\n\nfunc main() {}\n",
+		"notes.txt":  ".unknown\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	n, err := Lint(&out, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.slide")
+	want := []string{bad + ":5:1: ", bad + ":7:1: "}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if n != len(want) || len(lines) != len(want) {
+		t.Fatalf("expected %d problems got %d:\n%s", len(want), n, out.String())
+	}
+	for i, l := range lines {
+		if !strings.HasPrefix(l, want[i]) {
+			t.Errorf("expected a problem at %s got %s", want[i], l)
+		}
+	}
+
+	out.Reset()
+	n, err = Lint(&out, filepath.Join(dir, "good.slide"))
+	if err != nil || n != 0 {
+		t.Errorf("expected no problems got %d, %v:\n%s", n, err, out.String())
+	}
+}
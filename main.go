@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/gernest/vectypresent/format"
+	"github.com/gernest/vectypresent/lint"
 	"github.com/gernest/vectypresent/server"
 	"github.com/urfave/cli"
 )
@@ -15,6 +17,9 @@ func main() {
 	a.Usage = "present with vecty frontend"
 	a.Commands = []cli.Command{
 		server.Command(),
+		server.ExportCommand(),
+		lint.Command(),
+		format.Command(),
 	}
 	if err := a.Run(os.Args); err != nil {
 		fmt.Printf("vectypresent: %v\n", err)
@@ -0,0 +1,131 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// font is one of the standard Type 1 fonts, every PDF reader has them so they
+// are not embedded.
+type font int
+
+const (
+	regular font = iota
+	bold
+	italic
+	boldItalic
+	mono
+	monoBold
+	monoItalic
+)
+
+var fontNames = [...]string{
+	regular:    "Helvetica",
+	bold:       "Helvetica-Bold",
+	italic:     "Helvetica-Oblique",
+	boldItalic: "Helvetica-BoldOblique",
+	mono:       "Courier",
+	monoBold:   "Courier-Bold",
+	monoItalic: "Courier-Oblique",
+}
+
+// style returns the font with the given traits.
+func style(isBold, isItalic, isMono bool) font {
+	switch {
+	case isMono && isBold:
+		return monoBold
+	case isMono && isItalic:
+		return monoItalic
+	case isMono:
+		return mono
+	case isBold && isItalic:
+		return boldItalic
+	case isBold:
+		return bold
+	case isItalic:
+		return italic
+	}
+	return regular
+}
+
+// The widths of the printable ASCII characters, from space to tilde, in
+// thousandths of the font size. They come from the Adobe font metrics of the
+// standard fonts, the oblique fonts have the widths of the upright ones and
+// Courier is monospaced.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// width returns the width of s set in f at size.
+func (f font) width(s string, size float64) float64 {
+	var w int
+	for _, r := range s {
+		switch {
+		case f >= mono:
+			w += 600
+		case r < ' ' || r > '~':
+			// Other characters are about as wide as digits.
+			w += 556
+		case f == bold || f == boldItalic:
+			w += helveticaBoldWidths[r-' ']
+		default:
+			w += helveticaWidths[r-' ']
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+// winAnsi maps the characters of WinAnsiEncoding above 0x7f that are not in
+// Latin-1 to their codes.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// literal returns s as a PDF string literal in WinAnsiEncoding, characters the
+// encoding lacks are replaced by question marks.
+func literal(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		var c byte
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			c = byte(r)
+		case r >= ' ' && r <= '~':
+			c = byte(r)
+		case r >= 0xa0 && r <= 0xff:
+			c = byte(r)
+		default:
+			var ok bool
+			if c, ok = winAnsi[r]; !ok {
+				c = '?'
+			}
+		}
+		if c < ' ' || c > '~' {
+			fmt.Fprintf(&b, "\\%03o", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	b.WriteByte(')')
+	return b.String()
+}
@@ -0,0 +1,543 @@
+// Package pdf renders documents to PDF files, one landscape page per slide.
+// It is written in pure Go and uses the standard PDF fonts, so decks can be
+// exported offline without a browser.
+package pdf
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// The page has the size of a widescreen slide, one point per css pixel.
+const (
+	pageWidth  = 1100
+	pageHeight = 700
+	marginX    = 60
+	marginTop  = 50
+	// marginBottom is kept free, content below it goes on a continuation
+	// page.
+	marginBottom = 40
+	textWidth    = pageWidth - 2*marginX
+)
+
+var (
+	black    = rgb{0, 0, 0}
+	heading  = rgb{51, 51, 51}
+	grey     = rgb{102, 102, 102}
+	linkBlue = rgb{0, 102, 204}
+	codeBack = rgb{240, 240, 240}
+)
+
+// tokenStyles are the colors and fonts of highlighted code, from the default
+// theme of the slides.
+var tokenStyles = map[present.TokenKind]struct {
+	col  rgb
+	font font
+}{
+	present.Plain:   {black, mono},
+	present.Keyword: {rgb{0x8b, 0x00, 0x8b}, monoBold},
+	present.Builtin: {rgb{0x00, 0x68, 0x8b}, mono},
+	present.String:  {rgb{0xcd, 0x55, 0x55}, mono},
+	present.Number:  {rgb{0xb4, 0x52, 0xcd}, mono},
+	present.Comment: {rgb{0x22, 0x8b, 0x22}, monoItalic},
+}
+
+// Options configures the rendering of a document.
+type Options struct {
+	// Notes adds a page with the presenter notes after each slide that has
+	// some.
+	Notes bool
+
+	// ReadFile reads the images of the document, their urls are relative to
+	// the directory of the document. Images are left out if it is nil, and
+	// images on other hosts are always left out.
+	ReadFile func(name string) ([]byte, error)
+}
+
+// Write writes doc to w as a PDF file, with the title slide followed by a page
+// per section.
+func Write(w io.Writer, doc *models.Doc, opts Options) error {
+	r := &renderer{
+		opts:   opts,
+		images: make(map[string]*picture),
+	}
+	pages := r.d.reserve()
+	resources := r.d.reserve()
+
+	r.titlePage(doc)
+	r.notesPage(doc.Title, doc.TitleNotes)
+	for _, s := range doc.Sections {
+		r.slide(s)
+		r.notesPage(s.Title, s.Notes)
+	}
+	r.flush()
+
+	kids := make([]string, len(r.pages))
+	for i, c := range r.pages {
+		contents := r.d.stream("", c.b.Bytes())
+		page := r.d.add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %d %d] /Resources %d 0 R /Contents %d 0 R >>",
+			pages, pageWidth, pageHeight, resources, contents))
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	r.d.set(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+
+	var fonts strings.Builder
+	for f, name := range fontNames {
+		n := r.d.add("<< /Type /Font /Subtype /Type1 /BaseFont /" + name + " /Encoding /WinAnsiEncoding >>")
+		fmt.Fprintf(&fonts, " /F%d %d 0 R", f, n)
+	}
+	var images strings.Builder
+	for _, p := range r.pictures {
+		fmt.Fprintf(&images, " /%s %d 0 R", p.name, p.obj)
+	}
+	r.d.set(resources, fmt.Sprintf("<< /Font <<%s >> /XObject <<%s >> >>", fonts.String(), images.String()))
+
+	catalog := r.d.add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+	info := r.d.add("<< /Title " + literal(doc.Title) + " /Producer (vectypresent) >>")
+	return r.d.writeTo(w, catalog, info)
+}
+
+// picture is an image added to the document.
+type picture struct {
+	name          string
+	obj           int
+	width, height int
+}
+
+// renderer lays out the pages of a document.
+type renderer struct {
+	opts  Options
+	d     document
+	pages []*canvas
+
+	// images are the pictures by url, nil for images that can't be read.
+	images   map[string]*picture
+	pictures []*picture
+
+	// page is the page being laid out, y is the top of its free space.
+	page *canvas
+	y    float64
+}
+
+func (r *renderer) newPage() {
+	r.flush()
+	r.page = &canvas{height: pageHeight}
+	r.y = marginTop
+}
+
+// room starts a continuation page if the next h points don't fit on the page
+// being laid out. Content taller than a page is left to overflow.
+func (r *renderer) room(h float64) {
+	if r.y+h > pageHeight-marginBottom && r.y > marginTop {
+		r.newPage()
+	}
+}
+
+// flush adds the page being laid out to the document.
+func (r *renderer) flush() {
+	if r.page != nil {
+		r.pages = append(r.pages, r.page)
+		r.page = nil
+	}
+}
+
+func (r *renderer) titlePage(doc *models.Doc) {
+	r.newPage()
+	r.y = 220
+	r.paragraph(plain(doc.Title, bold, heading), marginX, textWidth, 60, 66)
+	if doc.Subtitle != "" {
+		r.paragraph(plain(doc.Subtitle, regular, heading), marginX, textWidth, 30, 40)
+	}
+	if !doc.Time.IsZero() {
+		r.paragraph(plain(doc.Time.Format(models.TimeFormat), regular, heading), marginX, textWidth, 30, 40)
+	}
+	r.y += 20
+	for _, a := range doc.Authors {
+		r.elems(a.Elem, 24)
+		r.y += 10
+	}
+}
+
+// slide lays out the page of s, sections without elements are shown as a
+// big title like the slides do.
+func (r *renderer) slide(s models.Section) {
+	r.newPage()
+	if len(s.Elem) == 0 {
+		r.y = pageHeight/2 - 20
+		r.paragraph(plain(s.Title, bold, heading), marginX, textWidth, 45, 54)
+		return
+	}
+	r.paragraph(plain(s.Title, bold, heading), marginX, textWidth, 30, 36)
+	r.y += 20
+	r.elems(s.Elem, 26)
+}
+
+// notesPage lays out a page with the presenter notes of the slide titled
+// title, if notes are enabled and there are any.
+func (r *renderer) notesPage(title string, notes []string) {
+	if !r.opts.Notes || len(notes) == 0 {
+		return
+	}
+	r.newPage()
+	r.paragraph(plain("Notes: "+title, bold, grey), marginX, textWidth, 24, 30)
+	r.y += 16
+	for _, n := range notes {
+		r.paragraph(styled(n, regular, black), marginX, textWidth, 20, 28)
+	}
+}
+
+// elems lays out the elements of a slide with text of the given size.
+func (r *renderer) elems(elems []models.Elem, size float64) {
+	lead := size * 36 / 26
+	for _, e := range elems {
+		switch v := e.(type) {
+		case models.Text:
+			if v.Pre {
+				r.code(strings.Split(v.Lines[0], "\n"), nil)
+				break
+			}
+			for _, l := range v.Lines {
+				r.paragraph(styled(l, regular, black), marginX, textWidth, size, lead)
+			}
+			r.y += lead / 2
+		case models.List:
+			for _, b := range v.Bullet {
+				r.room(lead)
+				r.page.text(marginX+10, baseline(r.y, size, lead), regular, size, black, "•")
+				r.paragraph(styled(b, regular, black), marginX+40, textWidth-40, size, lead)
+			}
+			r.y += lead / 2
+		case models.Code:
+			lines, tokens := codeTokens(v)
+			r.code(lines, tokens)
+		case models.Image:
+			r.image(v)
+		case models.Caption:
+			r.paragraph(styled(v.Text, italic, grey), marginX, textWidth, size*0.75, lead*0.75)
+		case models.Link:
+			label := v.Label
+			if label == "" && v.URL != nil {
+				label = v.URL.String()
+			}
+			r.paragraph(plain(label, regular, linkBlue), marginX+20, textWidth-20, size, lead)
+		case models.Iframe:
+			r.paragraph(plain(v.URL, regular, linkBlue), marginX, textWidth, size, lead)
+		case models.Video:
+			r.paragraph(plain(v.URL, regular, linkBlue), marginX, textWidth, size, lead)
+		case models.Section:
+			r.paragraph(plain(v.Title, bold, heading), marginX, textWidth, size, lead)
+			r.elems(v.Elem, size)
+		}
+	}
+}
+
+// hlCommentRE matches the comments marking highlighted lines, they are not
+// shown.
+var hlCommentRE = regexp.MustCompile(`(.+) // HL(.*)$`)
+
+// codeTokens returns the lines of the snippet c and their tokens, tokens is
+// nil if there is no lexer for the language of c.
+func codeTokens(c models.Code) (lines []string, tokens [][]present.Token) {
+	lines = strings.Split(strings.TrimRight(string(c.Raw), "\n"), "\n")
+	for i, l := range lines {
+		if m := hlCommentRE.FindStringSubmatch(l); m != nil {
+			l = m[1]
+		}
+		lines[i] = strings.Replace(l, "\t", "    ", -1)
+	}
+	toks := present.Tokens(strings.Join(lines, "\n"), c.Ext)
+	if toks == nil {
+		return lines, nil
+	}
+	tokens = make([][]present.Token, 1, len(lines))
+	for _, t := range toks {
+		// Tokens spanning several lines are split at line ends.
+		for i, part := range strings.Split(t.Text, "\n") {
+			if i > 0 {
+				tokens = append(tokens, nil)
+			}
+			if part != "" {
+				n := len(tokens) - 1
+				tokens[n] = append(tokens[n], present.Token{Kind: t.Kind, Text: part})
+			}
+		}
+	}
+	return lines, tokens
+}
+
+// code lays out a code block. Lines are highlighted with tokens, if any. The
+// font is made smaller for long lines, lines still too long are wrapped, and
+// blocks taller than the free space continue on the next page.
+func (r *renderer) code(lines []string, tokens [][]present.Token) {
+	const pad = 10
+	size, lead := 18.0, 24.0
+	rows := make([][]present.Token, len(lines))
+	longest := 0.0
+	for i, l := range lines {
+		if tokens != nil && i < len(tokens) {
+			rows[i] = tokens[i]
+		} else if l != "" {
+			rows[i] = []present.Token{{Kind: present.Plain, Text: l}}
+		}
+		if w := mono.width(l, size); w > longest {
+			longest = w
+		}
+	}
+	if free := float64(textWidth - 2*pad); longest > free {
+		size = math.Max(minCodeSize, size*free/longest)
+		lead = size * 4 / 3
+		rows = wrapTokens(rows, int(free/mono.width(" ", size)))
+	}
+	for len(rows) > 0 {
+		r.room(lead + 2*pad)
+		n := int((pageHeight - marginBottom - r.y - 2*pad) / lead)
+		if n < 1 {
+			n = 1
+		}
+		if n > len(rows) {
+			n = len(rows)
+		}
+		h := float64(n)*lead + 2*pad
+		r.page.rect(marginX, r.y, textWidth, h, codeBack)
+		y := r.y + pad
+		for _, row := range rows[:n] {
+			y += lead
+			base := y - (lead-size)/2 - size/6
+			x := float64(marginX + pad)
+			for _, t := range row {
+				st := tokenStyles[t.Kind]
+				r.page.text(x, base, st.font, size, st.col, t.Text)
+				x += st.font.width(t.Text, size)
+			}
+		}
+		r.y += h
+		rows = rows[n:]
+	}
+	r.y += 20
+}
+
+// minCodeSize is the smallest font size of code, longer lines are wrapped.
+const minCodeSize = 12
+
+// wrapTokens splits the rows of tokens longer than cols characters into
+// several rows.
+func wrapTokens(rows [][]present.Token, cols int) [][]present.Token {
+	if cols < 1 {
+		cols = 1
+	}
+	var wrapped [][]present.Token
+	for _, row := range rows {
+		var cur []present.Token
+		n := 0
+		for _, t := range row {
+			text := []rune(t.Text)
+			for len(text) > 0 {
+				if n == cols {
+					wrapped = append(wrapped, cur)
+					cur, n = nil, 0
+				}
+				k := cols - n
+				if k > len(text) {
+					k = len(text)
+				}
+				cur = append(cur, present.Token{Kind: t.Kind, Text: string(text[:k])})
+				n += k
+				text = text[k:]
+			}
+		}
+		wrapped = append(wrapped, cur)
+	}
+	return wrapped
+}
+
+// image lays out an image read with Options.ReadFile. It is scaled down to
+// fit the free space of the page.
+func (r *renderer) image(img models.Image) {
+	p := r.picture(img.URL)
+	if p == nil {
+		r.paragraph(plain("["+img.URL+"]", italic, grey), marginX, textWidth, 18, 24)
+		return
+	}
+	w, h := float64(img.Width), float64(img.Height)
+	switch {
+	case w == 0 && h == 0:
+		w, h = float64(p.width), float64(p.height)
+	case w == 0:
+		w = h * float64(p.width) / float64(p.height)
+	case h == 0:
+		h = w * float64(p.height) / float64(p.width)
+	}
+	r.room(100)
+	maxH := pageHeight - marginBottom - r.y
+	if w > textWidth {
+		w, h = textWidth, h*textWidth/w
+	}
+	if h > maxH {
+		w, h = w*maxH/h, maxH
+	}
+	r.page.image(p.name, marginX, r.y, w, h)
+	r.y += h + 20
+}
+
+// picture returns the image at u, adding it to the document the first time.
+// It returns nil for images that can't be read.
+func (r *renderer) picture(u string) *picture {
+	if p, ok := r.images[u]; ok {
+		return p
+	}
+	r.images[u] = nil
+	v, err := url.Parse(u)
+	if err != nil || v.IsAbs() || v.Host != "" || r.opts.ReadFile == nil {
+		return nil
+	}
+	data, err := r.opts.ReadFile(path.Clean(v.Path))
+	if err != nil {
+		return nil
+	}
+	n, w, h, err := r.d.addImage(data)
+	if err != nil || w == 0 || h == 0 {
+		return nil
+	}
+	p := &picture{name: fmt.Sprintf("Im%d", n), obj: n, width: w, height: h}
+	r.images[u] = p
+	r.pictures = append(r.pictures, p)
+	return p
+}
+
+// run is a piece of text set in a single font and color.
+type run struct {
+	text string
+	font font
+	col  rgb
+}
+
+func plain(s string, f font, col rgb) []run {
+	return []run{{s, f, col}}
+}
+
+// styled returns the runs of the text s with the font markers of present, like
+// *bold* and _italic_, and inline links. It reads the html made by
+// present.Style so the text is styled the same as in the browser.
+func styled(s string, f font, col rgb) []run {
+	h := string(present.Style(s))
+	isBold, isItalic := f == bold || f == boldItalic, f == italic || f == boldItalic
+	isMono, isLink := false, false
+	var runs []run
+	for h != "" {
+		i := strings.IndexByte(h, '<')
+		if i < 0 {
+			i = len(h)
+		}
+		if i > 0 {
+			c := col
+			if isLink {
+				c = linkBlue
+			}
+			runs = append(runs, run{html.UnescapeString(h[:i]), style(isBold, isItalic, isMono), c})
+		}
+		h = h[i:]
+		if h == "" {
+			break
+		}
+		end := strings.IndexByte(h, '>')
+		if end < 0 {
+			break
+		}
+		tag := strings.Fields(strings.Trim(h[1:end], "/"))
+		closing := h[1] == '/'
+		h = h[end+1:]
+		if len(tag) == 0 {
+			continue
+		}
+		switch tag[0] {
+		case "b":
+			isBold = !closing
+		case "i":
+			isItalic = !closing
+		case "code":
+			isMono = !closing
+		case "a":
+			isLink = !closing
+		}
+	}
+	return runs
+}
+
+// piece is a word or the space between words of a paragraph.
+type piece struct {
+	run
+	space bool
+	width float64
+}
+
+// paragraph lays out runs at x, wrapping the lines at width.
+func (r *renderer) paragraph(runs []run, x, width, size, lead float64) {
+	var pieces []piece
+	for _, rn := range runs {
+		for _, w := range splitWords(rn.text) {
+			pieces = append(pieces, piece{
+				run:   run{w, rn.font, rn.col},
+				space: strings.TrimSpace(w) == "",
+				width: rn.font.width(w, size),
+			})
+		}
+	}
+	var line []piece
+	lineWidth := 0.0
+	emit := func() {
+		r.room(lead)
+		r.y += lead
+		cx := x
+		for _, p := range line {
+			if !p.space {
+				r.page.text(cx, baseline(r.y-lead, size, lead), p.font, size, p.col, p.text)
+			}
+			cx += p.width
+		}
+		line, lineWidth = nil, 0
+	}
+	for _, p := range pieces {
+		if p.space && len(line) == 0 {
+			continue
+		}
+		if !p.space && len(line) > 0 && lineWidth+p.width > width {
+			// Spaces at the end of the line are dropped.
+			for len(line) > 0 && line[len(line)-1].space {
+				line = line[:len(line)-1]
+			}
+			emit()
+		}
+		line = append(line, p)
+		lineWidth += p.width
+	}
+	if len(line) > 0 {
+		emit()
+	}
+}
+
+// baseline returns the baseline of a line of text starting at y.
+func baseline(y, size, lead float64) float64 {
+	return y + lead - (lead-size)/2 - size*0.2
+}
+
+// splitWords splits s into words and the runs of spaces between them.
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	for i := 1; i <= len(s); i++ {
+		if i == len(s) || (s[i] == ' ') != (s[start] == ' ') {
+			words = append(words, s[start:i])
+			start = i
+		}
+	}
+	return words
+}
@@ -0,0 +1,193 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gernest/vectypresent/present"
+)
+
+const testDeck = `Title (draft)
+Subtitle
+
+Author
+
+* Section
+
+Some *bold* text
+
+: a presenter note
+
+* Code
+
+.code -numbers code.go
+
+* Image
+
+.image gopher.png 100 _
+.image https://golang.org/doc/gopher/frontpage.png
+
+* Big title
+`
+
+func TestWrite(t *testing.T) {
+	var img bytes.Buffer
+	m := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	m.Set(1, 1, color.NRGBA{R: 255, A: 128})
+	if err := png.Encode(&img, m); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"code.go":    []byte("package main\n\nfunc main() {} // HL\n"),
+		"gopher.png": img.Bytes(),
+	}
+	read := func(name string) ([]byte, error) {
+		if b, ok := files[name]; ok {
+			return b, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	ctx := present.Context{ReadFile: read, NoHighlight: true}
+	doc, err := ctx.Parse(strings.NewReader(testDeck), "test.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, notes := range []bool{false, true} {
+		var b bytes.Buffer
+		if err := Write(&b, doc, Options{Notes: notes, ReadFile: read}); err != nil {
+			t.Fatal(err)
+		}
+		out := b.Bytes()
+		if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) {
+			t.Fatalf("expected a pdf header got %q", out[:10])
+		}
+		pages := 5
+		if notes {
+			pages++
+		}
+		if n := bytes.Count(out, []byte("/Type /Page ")); n != pages {
+			t.Errorf("notes %v: expected %d pages got %d", notes, pages, n)
+		}
+		if n := bytes.Count(out, []byte("/Subtype /Image")); n != 1 {
+			t.Errorf("expected the local image only got %d images", n)
+		}
+		if !bytes.Contains(out, []byte("/Title (Title \\(draft\\))")) {
+			t.Error("expected the title in the document information")
+		}
+
+		// Every entry of the cross-reference table points at its object.
+		i := bytes.LastIndex(out, []byte("startxref\n"))
+		xref, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(string(out[i+10:]), "%%EOF\n")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+		if len(entries) == 0 {
+			t.Fatal("expected a cross-reference table")
+		}
+		for n, e := range entries {
+			off, _ := strconv.Atoi(string(e[1]))
+			obj := fmt.Sprintf("%d 0 obj\n", n+1)
+			if !bytes.HasPrefix(out[off:], []byte(obj)) {
+				t.Errorf("expected object %d at offset %d", n+1, off)
+			}
+		}
+	}
+}
+
+func TestLiteral(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{"plain", "(plain)"},
+		{`a (b) \c`, `(a \(b\) \\c)`},
+		{"café – €", `(caf\351 \226 \200)`},
+		{"tab\there 世界", `(tab?here ??)`},
+	}
+	for _, tt := range tests {
+		if got := literal(tt.in); got != tt.out {
+			t.Errorf("%q: expected %s got %s", tt.in, tt.out, got)
+		}
+	}
+}
+
+// textRE matches the text drawn on a page.
+var textRE = regexp.MustCompile(`/F(\d) (\S+) Tf (\S+) (\S+) Td \((.*)\) Tj ET`)
+
+func TestWriteOverflow(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("Title\n\n* Long list\n\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&src, "- bullet %d\n", i)
+	}
+	src.WriteString("\n* Tall code\n\n.code tall.go\n\n* Wide code\n\n.code wide.go\n\n* Notes\n\nText\n\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&src, ": note %d\n", i)
+	}
+	var tall strings.Builder
+	for i := 0; i < 60; i++ {
+		fmt.Fprintf(&tall, "var v%d = %d\n", i, i)
+	}
+	files := map[string][]byte{
+		"tall.go": []byte(tall.String()),
+		"wide.go": []byte("var s = \"" + strings.Repeat("x", 300) + "\"\n"),
+	}
+	read := func(name string) ([]byte, error) {
+		if b, ok := files[name]; ok {
+			return b, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	ctx := present.Context{ReadFile: read, NoHighlight: true}
+	doc, err := ctx.Parse(strings.NewReader(src.String()), "test.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &renderer{opts: Options{Notes: true}, images: make(map[string]*picture)}
+	var pages []int
+	for _, s := range doc.Sections {
+		n := len(r.pages)
+		r.slide(s)
+		r.notesPage(s.Title, s.Notes)
+		r.flush()
+		pages = append(pages, len(r.pages)-n)
+	}
+	// The list, the code and the notes continue on more pages, the wide code
+	// is the seventh page.
+	if want := []int{3, 3, 1, 3}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("expected %v pages per section got %v", want, pages)
+	}
+
+	var wide strings.Builder
+	for i, c := range r.pages {
+		for _, m := range textRE.FindAllStringSubmatch(c.b.String(), -1) {
+			f, _ := strconv.Atoi(m[1])
+			size, _ := strconv.ParseFloat(m[2], 64)
+			x, _ := strconv.ParseFloat(m[3], 64)
+			y, _ := strconv.ParseFloat(m[4], 64)
+			if y < marginBottom-size {
+				t.Errorf("page %d: %q is set below the page at %v", i, m[5], y)
+			}
+			if x+font(f).width(m[5], size) > pageWidth-marginX {
+				t.Errorf("page %d: %q runs off the page at %v", i, m[5], x)
+			}
+			if i == 6 && font(f) >= mono {
+				wide.WriteString(m[5])
+			}
+		}
+	}
+	if want := "var s = \"" + strings.Repeat("x", 300) + "\""; wide.String() != want {
+		t.Errorf("expected the wide line wrapped got %q", wide.String())
+	}
+}
@@ -0,0 +1,156 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // decoders of the images of decks
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strconv"
+)
+
+// document holds the objects of a PDF file as they are added. Objects are
+// numbered from 1 in the order they are added.
+type document struct {
+	objs [][]byte
+}
+
+// add adds an object with the given body and returns its number.
+func (d *document) add(body string) int {
+	d.objs = append(d.objs, []byte(body))
+	return len(d.objs)
+}
+
+// reserve returns the number of an object whose body is set later, so other
+// objects can refer to it.
+func (d *document) reserve() int {
+	return d.add("")
+}
+
+func (d *document) set(n int, body string) {
+	d.objs[n-1] = []byte(body)
+}
+
+// stream adds a stream object compressed with zlib. dict holds the entries of
+// the stream dictionary besides its filter and length.
+func (d *document) stream(dict string, data []byte) int {
+	var b bytes.Buffer
+	zw := zlib.NewWriter(&b)
+	zw.Write(data)
+	zw.Close()
+	return d.rawStream(dict+" /Filter /FlateDecode", b.Bytes())
+}
+
+// rawStream adds a stream object holding data as it is.
+func (d *document) rawStream(dict string, data []byte) int {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	b.Write(data)
+	b.WriteString("\nendstream")
+	d.objs = append(d.objs, b.Bytes())
+	return len(d.objs)
+}
+
+// writeTo writes the PDF file, root is the number of the catalog and info the
+// number of the information dictionary.
+func (d *document) writeTo(w io.Writer, root, info int) error {
+	var b bytes.Buffer
+	// The comment with bytes above 127 marks the file as binary.
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objs))
+	for i, o := range d.objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		b.Write(o)
+		b.WriteString("\nendobj\n")
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(d.objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(d.objs)+1, root, info, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// rgb is a color, with components between 0 and 255.
+type rgb struct{ r, g, b uint8 }
+
+func (c rgb) String() string {
+	return num(float64(c.r)/255) + " " + num(float64(c.g)/255) + " " + num(float64(c.b)/255)
+}
+
+// num formats a number of a content stream.
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// canvas builds the content stream of a page. Coordinates are in points from
+// the top left corner of the page, unlike PDF where they start at the bottom.
+type canvas struct {
+	b      bytes.Buffer
+	height float64
+}
+
+// text draws s with its baseline at y.
+func (c *canvas) text(x, y float64, f font, size float64, col rgb, s string) {
+	fmt.Fprintf(&c.b, "BT %s rg /F%d %s Tf %s %s Td %s Tj ET\n",
+		col, f, num(size), num(x), num(c.height-y), literal(s))
+}
+
+// rect fills the rectangle with its top left corner at x, y.
+func (c *canvas) rect(x, y, w, h float64, col rgb) {
+	fmt.Fprintf(&c.b, "%s rg %s %s %s %s re f\n", col, num(x), num(c.height-y-h), num(w), num(h))
+}
+
+// image draws the image XObject name with its top left corner at x, y.
+func (c *canvas) image(name string, x, y, w, h float64) {
+	fmt.Fprintf(&c.b, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(c.height-y-h), name)
+}
+
+// addImage adds the image encoded in data as an XObject, it returns the
+// object number and the size of the image in pixels. JPEG files are embedded
+// as they are, other images are decoded and drawn on a white background.
+func (d *document) addImage(data []byte) (n, width, height int, err error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if format == "jpeg" {
+		space := ""
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			space = "/DeviceGray"
+		case color.YCbCrModel:
+			space = "/DeviceRGB"
+		}
+		if space != "" {
+			n = d.rawStream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+				cfg.Width, cfg.Height, space), data)
+			return n, cfg.Width, cfg.Height, nil
+		}
+		// Other color models, like CMYK, are converted below.
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+	pix := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		pix = append(pix, rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2])
+	}
+	n = d.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+		bounds.Dx(), bounds.Dy()), pix)
+	return n, bounds.Dx(), bounds.Dy(), nil
+}
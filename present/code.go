@@ -34,6 +34,8 @@ var (
 // parseCode parses a code present directive. Its syntax:
 //   .code [-numbers] [-edit] <filename> [address] [highlight]
 // The directive may also be ".play" if the snippet is executable.
+// The address is either in the syntax of sam or selects a declaration of a Go
+// file, see declRE.
 func parseCode(ctx *Context, sourceFile string, sourceLine int, cmd string) (models.Elem, error) {
 	cmd = strings.TrimSpace(cmd)
 
@@ -41,7 +43,7 @@ func parseCode(ctx *Context, sourceFile string, sourceLine int, cmd string) (mod
 	highlight := ""
 	if hl := highlightRE.FindStringSubmatchIndex(cmd); len(hl) == 4 {
 		if hl[2] < 0 || hl[3] < 0 {
-			return nil, fmt.Errorf("%s:%d invalid highlight syntax", sourceFile, sourceLine)
+			return nil, &models.Error{File: sourceFile, Line: sourceLine, Msg: "invalid highlight syntax"}
 		}
 		highlight = cmd[hl[2]:hl[3]]
 		cmd = cmd[:hl[2]-2]
@@ -56,20 +58,20 @@ func parseCode(ctx *Context, sourceFile string, sourceLine int, cmd string) (mod
 	// args[4]: optional address
 	args := codeRE.FindStringSubmatch(cmd)
 	if len(args) != 5 {
-		return nil, fmt.Errorf("%s:%d: syntax error for .code/.play invocation", sourceFile, sourceLine)
+		return nil, &models.Error{File: sourceFile, Line: sourceLine, Msg: "syntax error for .code/.play invocation"}
 	}
 	command, flags, file, addr := args[1], args[2], args[3], strings.TrimSpace(args[4])
-	play := command == "play" && models.PlayEnabled
+	play := command == "play" && (ctx.Play || models.PlayEnabled)
 
 	// Read in code file and (optionally) match address.
 	filename := filepath.Join(filepath.Dir(sourceFile), file)
 	textBytes, err := ctx.ReadFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("%s:%d: %v", sourceFile, sourceLine, err)
+		return nil, &models.Error{File: sourceFile, Line: sourceLine, Msg: err.Error()}
 	}
-	lo, hi, err := addrToByteRange(addr, 0, textBytes)
+	lo, hi, err := codeRange(addr, file, textBytes)
 	if err != nil {
-		return nil, fmt.Errorf("%s:%d: %v", sourceFile, sourceLine, err)
+		return nil, &models.Error{File: sourceFile, Line: sourceLine, Msg: err.Error()}
 	}
 	if lo > hi {
 		// The search in addrToByteRange can wrap around so we might
@@ -90,8 +92,12 @@ func parseCode(ctx *Context, sourceFile string, sourceLine int, cmd string) (mod
 
 	lines := codeLines(textBytes, lo, hi)
 
+	formatted := formatLines(lines, highlight)
+	if !ctx.NoHighlight {
+		highlightLines(formatted, filepath.Ext(filename))
+	}
 	data := &codeTemplateData{
-		Lines:   formatLines(lines, highlight),
+		Lines:   formatted,
 		Edit:    strings.Contains(flags, "-edit"),
 		Numbers: strings.Contains(flags, "-numbers"),
 	}
@@ -156,7 +162,6 @@ type codeTemplateData struct {
 var leadingSpaceRE = regexp.MustCompile(`^[ \t]*`)
 
 var codeTemplate = template.Must(template.New("code").Funcs(template.FuncMap{
-	"trimSpace":    strings.TrimSpace,
 	"leadingSpace": leadingSpaceRE.FindString,
 }).Parse(codeTemplateHTML))
 
@@ -165,8 +170,8 @@ const codeTemplateHTML = `
 
 <pre{{if .Edit}} contenteditable="true" spellcheck="false"{{end}}{{if .Numbers}} class="numbers"{{end}}>{{/*
 	*/}}{{range .Lines}}<span num="{{.N}}">{{/*
-	*/}}{{if .HL}}{{leadingSpace .L}}<b>{{trimSpace .L}}</b>{{/*
-	*/}}{{else}}{{.L}}{{end}}{{/*
+	*/}}{{if .HL}}{{leadingSpace .L}}<b>{{.Trimmed}}</b>{{/*
+	*/}}{{else}}{{.HTML}}{{end}}{{/*
 */}}</span>
 {{end}}</pre>
 
@@ -175,9 +180,26 @@ const codeTemplateHTML = `
 
 // codeLine represents a line of code extracted from a source file.
 type codeLine struct {
-	L  string // The line of code.
-	N  int    // The line number from the source file.
-	HL bool   // Whether the line should be highlighted.
+	L  string        // The line of code.
+	N  int           // The line number from the source file.
+	HL bool          // Whether the line should be highlighted.
+	H  template.HTML // The syntax highlighted line, if there is a lexer for the file.
+}
+
+// HTML returns the line, syntax highlighted if possible.
+func (l codeLine) HTML() template.HTML {
+	if l.H == "" {
+		return template.HTML(template.HTMLEscapeString(l.L))
+	}
+	return l.H
+}
+
+// Trimmed returns the line without the surrounding white space.
+func (l codeLine) Trimmed() template.HTML {
+	if l.H == "" {
+		return template.HTML(template.HTMLEscapeString(strings.TrimSpace(l.L)))
+	}
+	return template.HTML(strings.TrimSpace(string(l.H)))
 }
 
 // codeLines takes a source file and returns the lines that
@@ -215,18 +237,18 @@ func parseArgs(name string, line int, args []string) (res []interface{}, err err
 	res = make([]interface{}, len(args))
 	for i, v := range args {
 		if len(v) == 0 {
-			return nil, fmt.Errorf("%s:%d bad code argument %q", name, line, v)
+			return nil, &models.Error{File: name, Line: line, Msg: fmt.Sprintf("bad code argument %q", v)}
 		}
 		switch v[0] {
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			n, err := strconv.Atoi(v)
 			if err != nil {
-				return nil, fmt.Errorf("%s:%d bad code argument %q", name, line, v)
+				return nil, &models.Error{File: name, Line: line, Msg: fmt.Sprintf("bad code argument %q", v)}
 			}
 			res[i] = n
 		case '/':
 			if len(v) < 2 || v[len(v)-1] != '/' {
-				return nil, fmt.Errorf("%s:%d bad code argument %q", name, line, v)
+				return nil, &models.Error{File: name, Line: line, Msg: fmt.Sprintf("bad code argument %q", v)}
 			}
 			res[i] = v
 		case '$':
@@ -238,7 +260,7 @@ func parseArgs(name string, line int, args []string) (res []interface{}, err err
 			}
 			fallthrough
 		default:
-			return nil, fmt.Errorf("%s:%d bad code argument %q", name, line, v)
+			return nil, &models.Error{File: name, Line: line, Msg: fmt.Sprintf("bad code argument %q", v)}
 		}
 	}
 	return
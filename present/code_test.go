@@ -158,6 +158,26 @@ func main() { // HLfunc
 			cmd:        ".code main.go /function main/",
 			err:        "main.go:0: no match for function main",
 		},
+		{
+			name:       "func main by name",
+			readFile:   read(helloTest, nil),
+			sourceFile: "main.go",
+			cmd:        ".code main.go func:main",
+			Code: models.Code{
+				Ext:      ".go",
+				FileName: "main.go",
+				Play:     false,
+				Raw:      []byte("func main() {\n\tfmt.Println(\"hello, test\")\n}"),
+				Text:     "<pre><span num=\"6\">func main() {</span>\n<span num=\"7\">    fmt.Println(&#34;hello, test&#34;)</span>\n<span num=\"8\">}</span>\n</pre>",
+			},
+		},
+		{
+			name:       "missing declaration",
+			readFile:   read(helloTest, nil),
+			sourceFile: "main.go",
+			cmd:        ".code main.go type:T",
+			err:        "main.go:0: type T not found in main.go",
+		},
 		{
 			name:       "all code with  numbers",
 			readFile:   read(helloTest, nil),
@@ -189,7 +209,8 @@ func main() { // HLfunc
 	trimBytes := func(b []byte) string { return strings.TrimSpace(string(b)) }
 
 	for _, tt := range tests {
-		ctx := &Context{tt.readFile}
+		// Syntax highlighting is covered by TestHighlight.
+		ctx := &Context{ReadFile: tt.readFile, NoHighlight: true}
 		e, err := parseCode(ctx, tt.sourceFile, 0, tt.cmd)
 		if err != nil {
 			if tt.err == "" {
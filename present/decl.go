@@ -0,0 +1,135 @@
+package present
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// declRE matches the declaration selectors of .code and .play, like func:main
+// or method:(*T).M. They select a declaration of a Go file by name, so
+// excerpts don't need regular expressions or OMIT markers to survive edits.
+var declRE = regexp.MustCompile(`^(func|method|type|const|var):(\S+)$`)
+
+// codeRange returns the byte range of data selected by addr, a declaration
+// selector or an address in the syntax of sam.
+func codeRange(addr, filename string, data []byte) (lo, hi int, err error) {
+	m := declRE.FindStringSubmatch(addr)
+	if m == nil {
+		return addrToByteRange(addr, 0, data)
+	}
+	return declToByteRange(m[1], m[2], filename, data)
+}
+
+// declToByteRange returns the byte range of the declaration of kind named name
+// in the Go source src. Specs of grouped declarations, like a type in a type
+// ( ... ) block, are selected without the group. Doc comments are left out.
+func declToByteRange(kind, name, filename string, src []byte) (lo, hi int, err error) {
+	fset := token.NewFileSet()
+	// Snippets with syntax errors may still hold the declaration, the error
+	// is only reported if it isn't found.
+	f, perr := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if f == nil {
+		return 0, 0, perr
+	}
+	var recv string
+	if kind == "method" {
+		i := strings.LastIndex(name, ".")
+		if i < 1 || i == len(name)-1 {
+			return 0, 0, fmt.Errorf("invalid method selector %q, expected (*T).M or T.M", name)
+		}
+		recv, name = recvName(name[:i]), name[i+1:]
+	}
+	var node ast.Node
+	for _, d := range f.Decls {
+		if node = matchDecl(d, kind, recv, name); node != nil {
+			break
+		}
+	}
+	if node == nil {
+		desc := kind + " " + name
+		if kind == "method" {
+			desc = "method " + recv + "." + name
+		}
+		if perr != nil {
+			return 0, 0, fmt.Errorf("%s not found in %s: %v", desc, filename, perr)
+		}
+		return 0, 0, fmt.Errorf("%s not found in %s", desc, filename)
+	}
+	file := fset.File(node.Pos())
+	return file.Offset(node.Pos()), file.Offset(node.End()), nil
+}
+
+// matchDecl returns the node of d declaring name, nil if there is none.
+func matchDecl(d ast.Decl, kind, recv, name string) ast.Node {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Name.Name != name {
+			return nil
+		}
+		switch {
+		case kind == "func" && d.Recv == nil:
+			return d
+		case kind == "method" && d.Recv != nil && len(d.Recv.List) == 1 &&
+			recvName(recvType(d.Recv.List[0].Type)) == recv:
+			return d
+		}
+	case *ast.GenDecl:
+		if d.Tok.String() != kind {
+			return nil
+		}
+		for _, spec := range d.Specs {
+			var names []*ast.Ident
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = []*ast.Ident{s.Name}
+			case *ast.ValueSpec:
+				names = s.Names
+			}
+			for _, n := range names {
+				if n.Name != name {
+					continue
+				}
+				if d.Lparen.IsValid() {
+					return spec
+				}
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// recvName returns the name of the type in the receiver expression s, without
+// the pointer, parentheses or type parameters: (*T[K]) gives T.
+func recvName(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "(")
+	s = strings.TrimSuffix(s, ")")
+	s = strings.TrimPrefix(strings.TrimSpace(s), "*")
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// recvType returns the source of the receiver type expression e, without type
+// parameters.
+func recvType(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.StarExpr:
+		return "*" + recvType(e.X)
+	case *ast.ParenExpr:
+		return recvType(e.X)
+	case *ast.IndexExpr:
+		return recvType(e.X)
+	case *ast.IndexListExpr:
+		return recvType(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
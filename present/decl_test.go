@@ -0,0 +1,85 @@
+package present
+
+import (
+	"strings"
+	"testing"
+)
+
+const declSrc = `package main
+
+import "fmt"
+
+// T is a type.
+type T struct {
+	n int
+}
+
+type (
+	A int
+	B[K comparable] map[K]int
+)
+
+const Answer = 42
+
+var (
+	x, y = 1, 2
+)
+
+func (t *T) Inc() {
+	t.n++
+}
+
+func (b B[K]) Len() int { return len(b) }
+
+func (a A) Inc() {}
+
+// main is documented.
+func main() {
+	fmt.Println("hello")
+}
+`
+
+func TestDeclToByteRange(t *testing.T) {
+	var tests = []struct {
+		addr string
+		want string
+		err  string
+	}{
+		{"func:main", "func main() {\n\tfmt.Println(\"hello\")\n}", ""},
+		{"type:T", "type T struct {\n\tn int\n}", ""},
+		{"type:A", "A int", ""},
+		{"type:B", "B[K comparable] map[K]int", ""},
+		{"const:Answer", "const Answer = 42", ""},
+		{"var:y", "x, y = 1, 2", ""},
+		{"method:(*T).Inc", "func (t *T) Inc() {\n\tt.n++\n}", ""},
+		{"method:T.Inc", "func (t *T) Inc() {\n\tt.n++\n}", ""},
+		{"method:A.Inc", "func (a A) Inc() {}", ""},
+		{"method:B.Len", "func (b B[K]) Len() int { return len(b) }", ""},
+		{"func:Inc", "", "func Inc not found in main.go"},
+		{"method:(*A).Len", "", "method A.Len not found in main.go"},
+		{"method:Inc", "", "invalid method selector"},
+		{"var:Answer", "", "var Answer not found"},
+		{"/^import/", "import", ""},
+	}
+	for _, tt := range tests {
+		lo, hi, err := codeRange(tt.addr, "main.go", []byte(declSrc))
+		if err != nil {
+			if tt.err == "" || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q got %v", tt.addr, tt.err, err)
+			}
+			continue
+		}
+		if tt.err != "" {
+			t.Errorf("%s: expected error %q got none", tt.addr, tt.err)
+			continue
+		}
+		if got := declSrc[lo:hi]; got != tt.want {
+			t.Errorf("%s: expected %q got %q", tt.addr, tt.want, got)
+		}
+	}
+
+	_, _, err := codeRange("func:main", "main.go", []byte("package main\n\nfunc f( {\n"))
+	if err == nil || !strings.Contains(err.Error(), "func main not found in main.go: main.go:3") {
+		t.Errorf("expected the syntax error to be reported got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package present
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// Format writes doc to w in the canonical present format. The document must
+// have been parsed in ParseComments mode, otherwise comments are lost and
+// elements created by directives can't be printed.
+//
+// Elements are separated by a single blank line, preformatted text is indented
+// with a tab, speaker notes follow the content of their section and comments
+// found inside a block of text or a list are moved after it. Directives that
+// failed to parse are printed as written.
+func Format(w io.Writer, doc *models.Doc) error {
+	p := &printer{w: bufio.NewWriter(w)}
+	p.doc(doc)
+	if p.err != nil {
+		return p.err
+	}
+	return p.w.Flush()
+}
+
+type printer struct {
+	w   *bufio.Writer
+	err error
+
+	// pre is the indent of the last element printed if it is preformatted
+	// text, a following block must use another one or they would be read
+	// as a single block.
+	pre string
+}
+
+func (p *printer) line(s string) {
+	p.raw(strings.TrimRight(s, " \t"))
+}
+
+// raw writes s as it is, followed by a newline.
+func (p *printer) raw(s string) {
+	if p.err != nil {
+		return
+	}
+	_, p.err = p.w.WriteString(s + "\n")
+}
+
+func (p *printer) lines(prefix string, lines []string) {
+	for _, l := range lines {
+		p.line(prefix + l)
+	}
+}
+
+func (p *printer) doc(doc *models.Doc) {
+	if len(doc.Comments) > 0 {
+		p.lines("", doc.Comments)
+		p.line("")
+	}
+	p.line(doc.Title)
+	if doc.Subtitle != "" {
+		p.line(doc.Subtitle)
+	}
+	if !doc.Time.IsZero() {
+		p.line(formatTime(doc))
+	}
+	if len(doc.Tags) > 0 {
+		p.line("Tags: " + strings.Join(doc.Tags, ", "))
+	}
+	if doc.Theme != "" {
+		p.line(themePrefix + " " + doc.Theme)
+	}
+	p.lines(": ", doc.TitleNotes)
+	for _, a := range doc.Authors {
+		p.line("")
+		for _, e := range a.Elem {
+			switch v := e.(type) {
+			case models.Text:
+				p.lines("", v.Lines)
+			case models.Link:
+				p.line(v.Label)
+			}
+		}
+	}
+	for _, s := range doc.Sections {
+		p.line("")
+		p.section(s)
+	}
+}
+
+// formatTime formats the document time the way parseTime reads it, dates
+// without a time are read as 11am UTC.
+func formatTime(doc *models.Doc) string {
+	if doc.Time.Hour() == 11 && doc.Time.Minute() == 0 {
+		return doc.Time.Format("2 Jan 2006")
+	}
+	return doc.Time.Format("15:04 2 Jan 2006")
+}
+
+func (p *printer) section(s models.Section) {
+	// The space of the heading is kept for slides without a title, without
+	// it the line would be read as text.
+	p.raw(strings.Repeat("*", len(s.Number)) + " " + strings.TrimRight(s.Title, " \t"))
+	p.pre = ""
+	for _, style := range s.Styles {
+		if url := strings.TrimPrefix(style, "background-image: url('"); url != style {
+			p.line(".background " + strings.TrimSuffix(url, "')"))
+		}
+	}
+	// Subsections are always last, everything up to the next heading belongs
+	// to them.
+	var subsections []models.Section
+	for _, e := range s.Elem {
+		if ss, ok := e.(models.Section); ok {
+			subsections = append(subsections, ss)
+			continue
+		}
+		p.line("")
+		p.elem(e)
+	}
+	if len(s.Notes) > 0 {
+		p.line("")
+		p.lines(": ", s.Notes)
+	}
+	for _, ss := range subsections {
+		p.line("")
+		p.section(ss)
+	}
+}
+
+func (p *printer) elem(e models.Elem) {
+	if t, ok := e.(models.Text); !ok || !t.Pre {
+		// Comments are skipped inside preformatted text, they don't end
+		// it.
+		if _, ok := e.(models.Comment); !ok {
+			p.pre = ""
+		}
+	}
+	switch v := e.(type) {
+	case models.Text:
+		if v.Pre {
+			pre := v.Raw
+			if pre == "" {
+				pre = strings.Join(v.Lines, "\n")
+			}
+			indent := "\t"
+			if p.pre == indent {
+				indent = "    "
+			}
+			for _, l := range strings.Split(pre, "\n") {
+				if l != "" {
+					l = indent + l
+				}
+				p.line(l)
+			}
+			p.pre = indent
+			return
+		}
+		for _, l := range v.Lines {
+			if strings.HasPrefix(l, ".") {
+				l = `\` + l
+			}
+			p.line(l)
+		}
+	case models.List:
+		p.lines("- ", v.Bullet)
+	case models.Comment:
+		p.lines("", v.Lines)
+	default:
+		cmd := directive(e)
+		if cmd == "" && p.err == nil {
+			p.err = fmt.Errorf("can't format %T without its directive text", e)
+		}
+		p.line(cmd)
+	}
+}
+
+// directive returns the text of the directive e was parsed from.
+func directive(e models.Elem) string {
+	switch v := e.(type) {
+	case models.Code:
+		return v.Cmd
+	case models.Image:
+		return v.Cmd
+	case models.Iframe:
+		return v.Cmd
+	case models.Video:
+		return v.Cmd
+	case models.Link:
+		return v.Cmd
+	case models.HTML:
+		return v.Cmd
+	case models.Caption:
+		return v.Cmd
+	}
+	return ""
+}
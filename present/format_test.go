@@ -0,0 +1,150 @@
+package present
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+const unformattedDoc = `# Copyright notice.
+
+Title
+Subtitle
+10 Jan 2018
+Theme:   dark
+Tags: foo,  bar
+: a title note
+
+Author
+@gopher
+
+* First
+.background bg.png
+: a note
+
+Some text
+\.not a directive
+# inside the text
+more text
+
+- one
+# between bullets
+- two
+
+.code main.go /^func main/
+
+
+	pre
+	  indented
+
+	after a blank line
+
+** Sub
+
+.caption   A caption
+
+* Second
+`
+
+const formattedDoc = `# Copyright notice.
+
+Title
+Subtitle
+10 Jan 2018
+Tags: foo, bar
+Theme: dark
+: a title note
+
+Author
+@gopher
+
+* First
+.background bg.png
+
+Some text
+\.not a directive
+more text
+
+# inside the text
+
+- one
+- two
+
+# between bullets
+
+.code main.go /^func main/
+
+	pre
+	  indented
+
+	after a blank line
+
+: a note
+
+** Sub
+
+.caption   A caption
+
+* Second
+`
+
+func TestFormat(t *testing.T) {
+	ctx := &Context{ReadFile: func(name string) ([]byte, error) {
+		if name == "main.go" {
+			return []byte("package main\n\nfunc main() {}\n"), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+	format := func(src string) string {
+		doc, err := ctx.Parse(strings.NewReader(src), "test.slide", ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Format(&buf, doc); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	got := format(unformattedDoc)
+	if got != formattedDoc {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if again := format(got); again != got {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	var tests = []string{
+		"Title\n\n* First\n\nText\n\n* \n\nUntitled\n\n* Next\n",
+		"Title\n\n* Pre\n\n\tone\n\n  two\n\n    three\n",
+		"Title\n\n* Pre\n\n\tone\n# a comment\n  two\n\nText\n\n  three\n",
+	}
+	ctx := &Context{ReadFile: func(string) ([]byte, error) { return nil, nil }}
+	for _, src := range tests {
+		doc, err := ctx.Parse(strings.NewReader(src), "test.slide", ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Format(&buf, doc); err != nil {
+			t.Fatal(err)
+		}
+		again, err := ctx.Parse(strings.NewReader(buf.String()), "test.slide", ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(again.Sections) != len(doc.Sections) {
+			t.Errorf("%q: expected %d sections got %d after formatting:\n%s", src, len(doc.Sections), len(again.Sections), buf.String())
+			continue
+		}
+		for i, s := range doc.Sections {
+			if got := again.Sections[i]; got.Title != s.Title || len(got.Elem) != len(s.Elem) {
+				t.Errorf("%q: expected section %q with %d elements got %q with %d after formatting:\n%s",
+					src, s.Title, len(s.Elem), got.Title, len(got.Elem), buf.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package present
+
+import (
+	"go/scanner"
+	"go/token"
+	"html/template"
+	"strings"
+)
+
+// TokenKind is the kind of a source code token, it selects the color of the
+// token in the highlighted code.
+type TokenKind int
+
+const (
+	Plain TokenKind = iota
+	Keyword
+	Builtin
+	String
+	Number
+	Comment
+)
+
+// class returns the css class of tokens of kind k.
+func (k TokenKind) class() string {
+	switch k {
+	case Keyword:
+		return "tok-kw"
+	case Builtin:
+		return "tok-bi"
+	case String:
+		return "tok-str"
+	case Number:
+		return "tok-num"
+	case Comment:
+		return "tok-com"
+	default:
+		return ""
+	}
+}
+
+// Token is a piece of source code of a kind.
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// A Lexer splits source code into tokens. The text of the tokens must add up
+// to src.
+type Lexer func(src string) []Token
+
+var lexers = map[string]Lexer{
+	".go": lexGo,
+}
+
+// RegisterLexer binds the lexer used to highlight the code of files with the
+// extension ext, which begins with a period.
+func RegisterLexer(ext string, lexer Lexer) {
+	if len(ext) < 2 || ext[0] != '.' {
+		panic("bad extension in RegisterLexer: " + ext)
+	}
+	lexers[ext] = lexer
+}
+
+// Tokens splits src into tokens with the lexer registered for ext. It returns
+// nil if there is none.
+func Tokens(src, ext string) []Token {
+	lex, ok := lexers[ext]
+	if !ok {
+		return nil
+	}
+	return lex(src)
+}
+
+// highlightLines sets the highlighted html of lines using the lexer registered
+// for ext. Lines are left alone if there is none.
+func highlightLines(lines []codeLine, ext string) {
+	lex, ok := lexers[ext]
+	if !ok {
+		return
+	}
+	src := make([]string, len(lines))
+	for i := range lines {
+		src[i] = lines[i].L
+	}
+	var b strings.Builder
+	n := 0
+	flush := func() {
+		lines[n].H = template.HTML(b.String())
+		b.Reset()
+		n++
+	}
+	for _, t := range lex(strings.Join(src, "\n")) {
+		// Tokens spanning several lines, like comments and raw strings, are
+		// closed at the end of every line.
+		for i, part := range strings.Split(t.Text, "\n") {
+			if i > 0 && n < len(lines) {
+				flush()
+			}
+			if part == "" {
+				continue
+			}
+			if class := t.Kind.class(); class != "" {
+				b.WriteString(`<span class="` + class + `">`)
+				b.WriteString(template.HTMLEscapeString(part))
+				b.WriteString(`</span>`)
+			} else {
+				b.WriteString(template.HTMLEscapeString(part))
+			}
+		}
+	}
+	if n < len(lines) {
+		flush()
+	}
+}
+
+// builtins are the predeclared identifiers of Go.
+var builtins = map[string]bool{
+	"bool": true, "byte": true, "complex64": true, "complex128": true,
+	"error": true, "float32": true, "float64": true, "int": true, "int8": true,
+	"int16": true, "int32": true, "int64": true, "rune": true, "string": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"uintptr": true, "any": true, "comparable": true,
+	"true": true, "false": true, "iota": true, "nil": true,
+	"append": true, "cap": true, "close": true, "complex": true, "copy": true,
+	"delete": true, "imag": true, "len": true, "make": true, "new": true,
+	"panic": true, "print": true, "println": true, "real": true, "recover": true,
+}
+
+// lexGo splits Go source code into tokens with go/scanner. Snippets don't need
+// to be complete files, the scanner only looks at the tokens.
+func lexGo(src string) []Token {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	// Errors, like unterminated strings cut by an address, leave the text
+	// as plain tokens.
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	var tokens []Token
+	last := 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON && lit != ";" {
+			// Semicolons inserted at line ends aren't in the source.
+			continue
+		}
+		off := file.Offset(pos)
+		end := off + len(lit)
+		if lit == "" {
+			end = off + len(tok.String())
+		}
+		if off < last || end > len(src) {
+			continue
+		}
+		kind := Plain
+		switch {
+		case tok.IsKeyword():
+			kind = Keyword
+		case tok == token.IDENT && builtins[lit]:
+			kind = Builtin
+		case tok == token.STRING || tok == token.CHAR:
+			kind = String
+		case tok == token.INT || tok == token.FLOAT || tok == token.IMAG:
+			kind = Number
+		case tok == token.COMMENT:
+			kind = Comment
+		}
+		if off > last {
+			tokens = append(tokens, Token{Plain, src[last:off]})
+		}
+		tokens = append(tokens, Token{kind, src[off:end]})
+		last = end
+	}
+	if last < len(src) {
+		tokens = append(tokens, Token{Plain, src[last:]})
+	}
+	return tokens
+}
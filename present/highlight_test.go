@@ -0,0 +1,81 @@
+package present
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+func TestHighlight(t *testing.T) {
+	src := []byte("package main\n\n/* a\nb */\nfunc f() int { // HLx\n\treturn len(`x\ny`) + 1 // done\n}\n")
+	var tests = []struct {
+		cmd   string
+		file  string
+		lines []string
+	}{
+		{".code -numbers main.go HLx", "main.go", []string{
+			`<span num="1"><span class="tok-kw">package</span> main</span>`,
+			`<span num="3"><span class="tok-com">/* a</span></span>`,
+			`<span num="4"><span class="tok-com">b */</span></span>`,
+			`<span num="5"><b><span class="tok-kw">func</span> f() <span class="tok-bi">int</span> {</b></span>`,
+			"<span num=\"6\">    <span class=\"tok-kw\">return</span> <span class=\"tok-bi\">len</span>(<span class=\"tok-str\">`x</span></span>",
+			"<span num=\"7\"><span class=\"tok-str\">y`</span>) + <span class=\"tok-num\">1</span> <span class=\"tok-com\">// done</span></span>",
+		}},
+		{".code main.txt", "main.txt", []string{
+			`<span num="5">func f() int {</span>`,
+		}},
+	}
+	for _, tt := range tests {
+		ctx := &Context{ReadFile: func(string) ([]byte, error) { return src, nil }}
+		e, err := parseCode(ctx, tt.file, 0, tt.cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := string(e.(models.Code).Text)
+		for _, l := range tt.lines {
+			if !strings.Contains(text, l) {
+				t.Errorf("%s: expected %q in\n%s", tt.cmd, l, text)
+			}
+		}
+	}
+}
+
+func TestRegisterLexer(t *testing.T) {
+	defer delete(lexers, ".test")
+	RegisterLexer(".test", func(src string) []Token {
+		return []Token{{Keyword, src}}
+	})
+	lines := []codeLine{{L: "a<b"}, {L: "c"}}
+	highlightLines(lines, ".test")
+	if lines[0].H != `<span class="tok-kw">a&lt;b</span>` || lines[1].H != `<span class="tok-kw">c</span>` {
+		t.Errorf("unexpected highlighting %q %q", lines[0].H, lines[1].H)
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	var tests = []struct {
+		header string
+		theme  string
+		err    string
+	}{
+		{"Theme: dark", "dark", ""},
+		{"Theme: Dark Blue", "", `invalid theme name "Dark Blue"`},
+	}
+	for _, tt := range tests {
+		src := "Title\n" + tt.header + "\n\nAuthor\n\n* Section\n"
+		doc, err := Parse(strings.NewReader(src), "test.slide", 0)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q got %v", tt.header, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Theme != tt.theme {
+			t.Errorf("%s: expected theme %q got %q", tt.header, tt.theme, doc.Theme)
+		}
+	}
+}
@@ -23,5 +23,8 @@ func parseHTML(ctx *Context, fileName string, lineno int, text string) (models.E
 	if err != nil {
 		return nil, err
 	}
+	if !ctx.TrustHTML {
+		return models.HTML{HTML: SanitizeHTML(string(b))}, nil
+	}
 	return models.HTML{HTML: template.HTML(b)}, nil
 }
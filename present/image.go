@@ -17,6 +17,12 @@ func init() {
 
 func parseImage(ctx *Context, fileName string, lineno int, text string) (models.Elem, error) {
 	args := strings.Fields(text)
+	if len(args) < 2 {
+		return nil, fmt.Errorf("incorrect image invocation: %q", text)
+	}
+	if err := ctx.checkURL(args[1]); err != nil {
+		return nil, err
+	}
 	img := models.Image{URL: args[1]}
 	a, err := parseArgs(fileName, lineno, args[2:])
 	if err != nil {
@@ -17,6 +17,8 @@ func init() {
 	Register("link", parseLink)
 }
 
+const badURLChars = `<>"{}|\^[] ` + "`" // per RFC2396 section 2.4.3
+
 func parseLink(ctx *Context, fileName string, lineno int, text string) (models.Elem, error) {
 	args := strings.Fields(text)
 	if len(args) < 2 {
@@ -26,6 +28,9 @@ func parseLink(ctx *Context, fileName string, lineno int, text string) (models.E
 	if err != nil {
 		return nil, err
 	}
+	if err := ctx.checkURL(args[1]); err != nil {
+		return nil, err
+	}
 	label := ""
 	if len(args) > 2 {
 		label = strings.Join(args[2:], " ")
@@ -68,7 +73,6 @@ func parseInlineLink(s string) (link string, length int) {
 	}
 	urlEnd := strings.Index(s, "]")
 	rawURL := s[2:urlEnd]
-	const badURLChars = `<>"{}|\^[] ` + "`" // per RFC2396 section 2.4.3
 	if strings.ContainsAny(rawURL, badURLChars) {
 		return
 	}
@@ -0,0 +1,171 @@
+package present
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// Lint parses the document read from r and returns every problem found in it,
+// sorted by position. In addition to the errors reported by Parse in AllErrors
+// mode it checks for unreadable .image and .background targets, unmatched font
+// markers, malformed inline links and empty sections.
+//
+// The returned error is only set if the document can't be parsed at all.
+func (ctx *Context) Lint(r io.Reader, name string) ([]models.Error, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := ctx.Parse(strings.NewReader(strings.Join(lines.Text, "\n")), name, AllErrors)
+	if err != nil {
+		if e, ok := err.(*models.Error); ok {
+			return []models.Error{*e}, nil
+		}
+		return nil, err
+	}
+	errs := doc.Diagnostics
+	for i := range errs {
+		// Directive problems are reported for the whole line.
+		if errs[i].Col == 0 {
+			errs[i].Col = 1
+		}
+	}
+	add := func(line, col int, format string, args ...interface{}) {
+		errs = append(errs, models.Error{
+			File: name,
+			Line: line,
+			Col:  col,
+			Msg:  fmt.Sprintf(format, args...),
+		})
+	}
+	body := false
+	for i, text := range lines.Text {
+		n := i + 1
+		if isHeading.MatchString(text) {
+			body = true
+			if emptySection(lines.Text[i+1:], strings.Index(text, " ")) {
+				add(n, 1, "empty section %q", strings.TrimSpace(strings.TrimLeft(text, "*")))
+			}
+			continue
+		}
+		// The header and authors are not styled.
+		if !body || text == "" {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(text)
+		switch {
+		case unicode.IsSpace(r), text[0] == '#', isSpeakerNote(text):
+		case strings.HasPrefix(text, "- "):
+			lintStyle(text[2:], 3, func(col int, msg string) { add(n, col, "%s", msg) })
+		case strings.HasPrefix(text, ".caption "):
+			lintStyle(text[len(".caption "):], len(".caption ")+1, func(col int, msg string) { add(n, col, "%s", msg) })
+		case strings.HasPrefix(text, ".image "), strings.HasPrefix(text, ".background "):
+			args := strings.Fields(text)
+			if len(args) < 2 || isURL(args[1]) {
+				continue
+			}
+			target := filepath.Join(filepath.Dir(name), args[1])
+			if _, err := ctx.ReadFile(target); err != nil {
+				if e, ok := err.(*fs.PathError); ok {
+					err = e.Err
+				}
+				add(n, strings.Index(text, args[1])+1, "unreadable file %q: %v", args[1], err)
+			}
+		case text[0] == '.':
+		default:
+			text = strings.TrimPrefix(text, `\`)
+			lintStyle(text, 1, func(col int, msg string) { add(n, col, "%s", msg) })
+		}
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Col < errs[j].Col
+	})
+	return errs, nil
+}
+
+// emptySection returns true if the section whose heading has the given level
+// has no content before the next section of the same or a higher level.
+func emptySection(rest []string, level int) bool {
+	for _, text := range rest {
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		if isHeading.MatchString(text) {
+			return strings.Index(text, " ") <= level
+		}
+		return false
+	}
+	return true
+}
+
+// lintStyle reports unmatched font markers and malformed inline links in the
+// styled text s. Columns are offset by col, the column s starts at.
+func lintStyle(s string, col int, report func(col int, msg string)) {
+	for i := 0; ; {
+		j := strings.Index(s[i:], "[[")
+		if j < 0 {
+			break
+		}
+		i += j
+		_, length := parseInlineLink(s[i:])
+		if length == 0 {
+			report(col+i, "malformed inline link")
+			i += 2
+			continue
+		}
+		i += length
+	}
+	offset := 0
+	for _, word := range split(s) {
+		if n := unmatchedMarker(word); n >= 0 {
+			report(col+offset+n, fmt.Sprintf("unmatched font marker %q", word[n]))
+		}
+		offset += len(word)
+	}
+}
+
+// unmatchedMarker returns the offset of an opening font marker in word that has
+// no closing marker, or -1. It follows the rules used by font.
+func unmatchedMarker(word string) int {
+	if len(word) < 2 || strings.HasPrefix(word, "[[") {
+		return -1
+	}
+	first := strings.IndexAny(word, "_*`")
+	if first == -1 {
+		return -1
+	}
+	if first != 0 {
+		r, _ := utf8.DecodeLastRuneInString(word[:first])
+		if !unicode.IsPunct(r) {
+			return -1
+		}
+	}
+	rest := word[first:]
+	last := strings.LastIndex(rest, rest[:1])
+	if last == 0 {
+		return first
+	}
+	if last+1 != len(rest) {
+		r, _ := utf8.DecodeRuneInString(rest[last+1:])
+		if !unicode.IsPunct(r) {
+			return first
+		}
+	}
+	return -1
+}
+
+// isURL returns true if s is an absolute url rather than a file name.
+func isURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(s, "data:")
+}
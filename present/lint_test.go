@@ -0,0 +1,52 @@
+package present
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const lintDoc = `Title
+
+Author
+
+* Intro
+
+This is _unclosed and [[http://golang.org][bad link
+- a *bold* bullet
+- a ` + "`broken" + ` bullet
+
+.image missing.png
+.image http://golang.org/doc/gopher/frontpage.png
+
+* Empty
+
+* Fine
+
+Text *ok*.
+`
+
+func TestLint(t *testing.T) {
+	ctx := &Context{ReadFile: func(string) ([]byte, error) {
+		return nil, os.ErrNotExist
+	}}
+	errs, err := ctx.Lint(strings.NewReader(lintDoc), "test.slide")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"test.slide:7:9: unmatched font marker '_'",
+		"test.slide:7:23: malformed inline link",
+		"test.slide:9:5: unmatched font marker '`'",
+		`test.slide:11:8: unreadable file "missing.png": file does not exist`,
+		`test.slide:14:1: empty section "Empty"`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), errs)
+	}
+	for i := range want {
+		if got := errs[i].Error(); got != want[i] {
+			t.Errorf("#%d: expected %q got %q", i, want[i], got)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Version is the version of the JSON document format written by Encode.
+//
+// A document is encoded as
+//
+//	{"version": 1, "doc": {"title": ..., "sections": [...], ...}}
+//
+// Every element, in sections and authors alike, is an object whose "type"
+// field is the TemplateName of the element ("text", "list", "code", "image",
+// "link", "html", "iframe", "video", "caption", "comment" or "section") and
+// whose other fields are those of the element type, named by their json tags.
+// Links encode their url as a string. The version is incremented whenever a
+// change would break existing readers.
+const Version = 1
+
+type document struct {
+	Version int  `json:"version"`
+	Doc     *Doc `json:"doc"`
+}
+
+// Encode writes doc to w in the versioned JSON document format.
+func Encode(w io.Writer, doc *Doc) error {
+	return json.NewEncoder(w).Encode(document{Version: Version, Doc: doc})
+}
+
+// Decode reads a document in the JSON document format from r into doc.
+func Decode(r io.Reader, doc *Doc) error {
+	d := document{Doc: doc}
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return err
+	}
+	if d.Version != Version {
+		return fmt.Errorf("unsupported document version %d", d.Version)
+	}
+	return nil
+}
+
+// The MarshalJSON methods add the type discriminator to the encoding of
+// elements. They encode a copy of the element whose type has no methods, so
+// that they don't recurse.
+
+func (e Text) MarshalJSON() ([]byte, error) {
+	type text Text
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		text
+	}{e.TemplateName(), text(e)})
+}
+
+func (e List) MarshalJSON() ([]byte, error) {
+	type list List
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		list
+	}{e.TemplateName(), list(e)})
+}
+
+func (e Code) MarshalJSON() ([]byte, error) {
+	type code Code
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		code
+	}{e.TemplateName(), code(e)})
+}
+
+func (e Image) MarshalJSON() ([]byte, error) {
+	type image Image
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		image
+	}{e.TemplateName(), image(e)})
+}
+
+func (e HTML) MarshalJSON() ([]byte, error) {
+	type html HTML
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		html
+	}{e.TemplateName(), html(e)})
+}
+
+func (e Iframe) MarshalJSON() ([]byte, error) {
+	type iframe Iframe
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		iframe
+	}{e.TemplateName(), iframe(e)})
+}
+
+func (e Video) MarshalJSON() ([]byte, error) {
+	type video Video
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		video
+	}{e.TemplateName(), video(e)})
+}
+
+func (e Caption) MarshalJSON() ([]byte, error) {
+	type caption Caption
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		caption
+	}{e.TemplateName(), caption(e)})
+}
+
+func (e Comment) MarshalJSON() ([]byte, error) {
+	type comment Comment
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		comment
+	}{e.TemplateName(), comment(e)})
+}
+
+func (e Section) MarshalJSON() ([]byte, error) {
+	type section Section
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		section
+	}{e.TemplateName(), section(e)})
+}
+
+// UnmarshalJSON decodes the elements of the section using their type.
+func (e *Section) UnmarshalJSON(b []byte) error {
+	type section Section
+	var v struct {
+		section
+		Elem []json.RawMessage `json:"elem"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	elems, err := decodeElems(v.Elem)
+	if err != nil {
+		return err
+	}
+	*e = Section(v.section)
+	e.Elem = elems
+	return nil
+}
+
+// UnmarshalJSON decodes the elements of the author using their type.
+func (a *Author) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Elem []json.RawMessage `json:"elem"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	elems, err := decodeElems(v.Elem)
+	if err != nil {
+		return err
+	}
+	a.Elem = elems
+	return nil
+}
+
+type jsonLink struct {
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+	Label string `json:"label"`
+	Cmd   string `json:"cmd,omitempty"`
+}
+
+func (e Link) MarshalJSON() ([]byte, error) {
+	v := jsonLink{Type: e.TemplateName(), Label: e.Label, Cmd: e.Cmd}
+	if e.URL != nil {
+		v.URL = e.URL.String()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON parses the url of the link.
+func (e *Link) UnmarshalJSON(b []byte) error {
+	var v jsonLink
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	u, err := url.Parse(v.URL)
+	if err != nil {
+		return err
+	}
+	*e = Link{URL: u, Label: v.Label, Cmd: v.Cmd}
+	return nil
+}
+
+// decodeElems decodes elements using their type discriminator.
+func decodeElems(raw []json.RawMessage) ([]Elem, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	elems := make([]Elem, 0, len(raw))
+	for _, r := range raw {
+		var t struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(r, &t); err != nil {
+			return nil, err
+		}
+		var e Elem
+		var err error
+		switch t.Type {
+		case "text":
+			var v Text
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "list":
+			var v List
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "code":
+			var v Code
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "image":
+			var v Image
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "link":
+			var v Link
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "html":
+			var v HTML
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "iframe":
+			var v Iframe
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "video":
+			var v Video
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "caption":
+			var v Caption
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "comment":
+			var v Comment
+			err = json.Unmarshal(r, &v)
+			e = v
+		case "section":
+			var v Section
+			err = json.Unmarshal(r, &v)
+			e = v
+		default:
+			return nil, fmt.Errorf("unknown element type %q", t.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+	}
+	return elems, nil
+}
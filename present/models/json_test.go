@@ -0,0 +1,115 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func testDoc() *Doc {
+	u, _ := url.Parse("https://golang.org/doc")
+	return &Doc{
+		Title:    "Title",
+		Subtitle: "Subtitle",
+		Time:     time.Date(2018, time.January, 10, 11, 0, 0, 0, time.UTC),
+		Authors: []Author{
+			{Elem: []Elem{
+				Text{Lines: []string{"Gopher"}},
+				Link{URL: u, Label: "golang.org/doc"},
+			}},
+		},
+		TitleNotes: []string{"a title note"},
+		Tags:       []string{"go", "present"},
+		Sections: []Section{
+			{
+				Number: []int{1},
+				Title:  "First",
+				Elem: []Elem{
+					Text{Lines: []string{"Some *text*"}},
+					Text{Lines: []string{"pre"}, Pre: true},
+					List{Bullet: []string{"one", "two"}},
+					Code{
+						Text:     "<pre>package main</pre>",
+						Play:     true,
+						FileName: "main.go",
+						Ext:      ".go",
+						Raw:      []byte("package main\n"),
+						Cmd:      ".play main.go",
+					},
+					Image{URL: "gopher.png", Width: 100, Cmd: ".image gopher.png _ 100"},
+					Caption{Text: "A gopher", Cmd: ".caption A gopher"},
+					Link{URL: u, Label: "docs", Cmd: ".link https://golang.org/doc docs"},
+					HTML{HTML: "<b>bold</b>", Cmd: ".html bold.html"},
+					Iframe{URL: "https://golang.org", Width: 800, Height: 600},
+					Video{URL: "talk.mp4", SourceType: "video/mp4", Width: 640, Height: 480},
+					Comment{Lines: []string{"# a comment"}},
+					Section{
+						Number: []int{1, 1},
+						Title:  "Nested",
+						Elem:   []Elem{Text{Lines: []string{"inside"}}},
+					},
+				},
+				Notes:   []string{"a note"},
+				Classes: []string{"background"},
+				Styles:  []string{"background-image: url('bg.png')"},
+			},
+		},
+	}
+}
+
+func TestJSONGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testDoc()); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := json.Indent(&got, buf.Bytes(), "", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "doc.golden.json")
+	if *update {
+		if err := ioutil.WriteFile(golden, got.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("encoding doesn't match %s, run go test -update:\n%s", golden, got.Bytes())
+	}
+
+	doc := &Doc{}
+	if err := Decode(bytes.NewReader(want), doc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc, testDoc()) {
+		t.Errorf("decoding %s doesn't match the document:\n%#v", golden, doc)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var tests = []struct {
+		in  string
+		err string
+	}{
+		{`{"version":2,"doc":{}}`, "unsupported document version 2"},
+		{`{"version":1,"doc":{"sections":[{"elem":[{"type":"bogus"}]}]}}`, `unknown element type "bogus"`},
+	}
+	for _, tt := range tests {
+		err := Decode(strings.NewReader(tt.in), &Doc{})
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: expected error %q got %v", tt.in, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Meta is the header of a document, shown in directory listings.
+type Meta struct {
+	Title    string    `json:"title"`
+	Subtitle string    `json:"subtitle,omitempty"`
+	Time     time.Time `json:"time"`
+	Authors  []string  `json:"authors,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// NewMeta returns the header of doc.
+func NewMeta(doc *Doc) *Meta {
+	m := &Meta{
+		Title:    doc.Title,
+		Subtitle: doc.Subtitle,
+		Time:     doc.Time,
+		Tags:     doc.Tags,
+	}
+	for i := range doc.Authors {
+		if name := doc.Authors[i].Name(); name != "" {
+			m.Authors = append(m.Authors, name)
+		}
+	}
+	return m
+}
+
+// HasTag returns true if the document is tagged with tag.
+func (m *Meta) HasTag(tag string) bool {
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Tags returns the tags of the documents under d, sorted by name.
+func (d *File) Tags() []string {
+	seen := make(map[string]bool)
+	d.walk(func(f *File) {
+		if f.Meta != nil {
+			for _, t := range f.Meta.Tags {
+				seen[t] = true
+			}
+		}
+	})
+	tags := make([]string, 0, len(seen))
+	for t := range seen {
+		tags = append(tags, t)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Tagged returns the documents under d tagged with tag, newest first.
+func (d *File) Tagged(tag string) []*File {
+	var files []*File
+	d.walk(func(f *File) {
+		if f.Meta != nil && f.Meta.HasTag(tag) {
+			files = append(files, f)
+		}
+	})
+	SortByTime(files)
+	return files
+}
+
+func (d *File) walk(fn func(*File)) {
+	fn(d)
+	for _, c := range d.Children {
+		c.walk(fn)
+	}
+}
+
+// SortByTime sorts files newest first, files without a date come last in
+// their original order.
+func SortByTime(files []*File) {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].time().After(files[j].time())
+	})
+}
+
+func (d *File) time() time.Time {
+	if d.Meta == nil {
+		return time.Time{}
+	}
+	return d.Meta.Time
+}
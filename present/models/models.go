@@ -2,7 +2,6 @@ package models
 
 import (
 	"bytes"
-	"encoding/gob"
 	"fmt"
 	"html/template"
 	"io"
@@ -13,23 +12,6 @@ import (
 	"time"
 )
 
-func init() {
-	gob.Register(Text{})
-	gob.Register(Code{})
-	gob.Register(List{})
-	gob.Register(Link{})
-	gob.Register(Image{})
-	gob.Register(Caption{})
-}
-
-func Encode(o io.Writer, v interface{}) error {
-	return gob.NewEncoder(o).Encode(v)
-}
-
-func Decode(o io.Reader, v interface{}) error {
-	return gob.NewDecoder(o).Decode(v)
-}
-
 // TODO(adg): replace the PlayEnabled flag with something less spaghetti-like.
 // Instead this will probably be determined by a template execution Context
 // value that contains various global metadata required when rendering
@@ -45,41 +27,72 @@ var PlayEnabled = false
 
 const TimeFormat = "2 January 2006"
 
+// Error is a problem found in a document, positioned at the line where it
+// occurred.
+type Error struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	Col  int    `json:"col,omitempty"`
+	Msg  string `json:"message"`
+
+	// Source holds the lines surrounding Line. It is filled in by the server
+	// so the offending line can be displayed.
+	Source []SourceLine `json:"source,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Col > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
+}
+
+// SourceLine is a numbered line of a source file.
+type SourceLine struct {
+	N    int    `json:"n"`
+	Text string `json:"text"`
+}
+
 type Caption struct {
-	Text string
+	Text string `json:"text"`
+	Cmd  string `json:"cmd,omitempty"` // the directive text it was parsed from
 }
 
 func (c Caption) TemplateName() string { return "caption" }
 
 type Code struct {
-	Text     template.HTML
-	Play     bool   // runnable code
-	Edit     bool   // editable code
-	FileName string // file name
-	Ext      string // file extension
-	Raw      []byte // content of the file
+	Text     template.HTML `json:"text"`
+	Play     bool          `json:"play,omitempty"` // runnable code
+	Edit     bool          `json:"edit,omitempty"` // editable code
+	FileName string        `json:"fileName"`       // file name
+	Ext      string        `json:"ext"`            // file extension
+	Raw      []byte        `json:"raw"`            // content of the file
+	Cmd      string        `json:"cmd,omitempty"`  // the directive text it was parsed from
 }
 
 func (c Code) TemplateName() string { return "code" }
 
 type HTML struct {
-	template.HTML
+	template.HTML `json:"html"`
+	Cmd           string `json:"cmd,omitempty"` // the directive text it was parsed from
 }
 
 func (s HTML) TemplateName() string { return "html" }
 
 type Iframe struct {
-	URL    string
-	Width  int
-	Height int
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Cmd    string `json:"cmd,omitempty"` // the directive text it was parsed from
 }
 
 func (i Iframe) TemplateName() string { return "iframe" }
 
 type Image struct {
-	URL    string
-	Width  int
-	Height int
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Cmd    string `json:"cmd,omitempty"` // the directive text it was parsed from
 }
 
 func (i Image) TemplateName() string { return "image" }
@@ -87,28 +100,41 @@ func (i Image) TemplateName() string { return "image" }
 type Link struct {
 	URL   *url.URL
 	Label string
+	Cmd   string // the directive text it was parsed from
 }
 
 func (l Link) TemplateName() string { return "link" }
 
 type Video struct {
-	URL        string
-	SourceType string
-	Width      int
-	Height     int
+	URL        string `json:"url"`
+	SourceType string `json:"sourceType"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	Cmd        string `json:"cmd,omitempty"` // the directive text it was parsed from
 }
 
 func (v Video) TemplateName() string { return "video" }
 
 // Doc represents an entire document.
 type Doc struct {
-	Title      string
-	Subtitle   string
-	Time       time.Time
-	Authors    []Author
-	TitleNotes []string
-	Sections   []Section
-	Tags       []string
+	Title      string    `json:"title"`
+	Subtitle   string    `json:"subtitle,omitempty"`
+	Time       time.Time `json:"time"`
+	Authors    []Author  `json:"authors,omitempty"`
+	TitleNotes []string  `json:"titleNotes,omitempty"`
+	Sections   []Section `json:"sections"`
+	Tags       []string  `json:"tags,omitempty"`
+
+	// Theme names the colors of the syntax highlighted code, set with a
+	// "Theme: name" header line.
+	Theme string `json:"theme,omitempty"`
+
+	// Diagnostics are the problems found while parsing in AllErrors mode.
+	Diagnostics []Error `json:"diagnostics,omitempty"`
+
+	// Comments are the comment lines found in the header and authors, they
+	// are only recorded in ParseComments mode.
+	Comments []string `json:"comments,omitempty"`
 }
 
 // Render renders the doc to the given writer using the provided template.
@@ -122,9 +148,17 @@ func (d *Doc) Render(w io.Writer, t *template.Template) error {
 	return t.ExecuteTemplate(w, "root", data)
 }
 
+// ThemeClass returns the css class selecting the theme of the document.
+func (d *Doc) ThemeClass() string {
+	if d.Theme == "" {
+		return "theme-default"
+	}
+	return "theme-" + d.Theme
+}
+
 // Author represents the person who wrote and/or is presenting the document.
 type Author struct {
-	Elem []Elem
+	Elem []Elem `json:"elem"`
 }
 
 // TextElem returns the first text elements of the author details.
@@ -140,15 +174,25 @@ func (p *Author) TextElem() (elems []Elem) {
 	return
 }
 
+// Name returns the first line of the author details, usually the name.
+func (p *Author) Name() string {
+	for _, el := range p.Elem {
+		if t, ok := el.(Text); ok && len(t.Lines) > 0 {
+			return t.Lines[0]
+		}
+	}
+	return ""
+}
+
 // Section represents a section of a document (such as a presentation slide)
 // comprising a title and a list of elements.
 type Section struct {
-	Number  []int
-	Title   string
-	Elem    []Elem
-	Notes   []string
-	Classes []string
-	Styles  []string
+	Number  []int    `json:"number"`
+	Title   string   `json:"title"`
+	Elem    []Elem   `json:"elem"`
+	Notes   []string `json:"notes,omitempty"`
+	Classes []string `json:"classes,omitempty"`
+	Styles  []string `json:"styles,omitempty"`
 }
 
 // Render renders the section to the given writer using the provided template.
@@ -214,15 +258,27 @@ type Elem interface {
 
 // Text represents an optionally preformatted paragraph.
 type Text struct {
-	Lines []string
-	Pre   bool
+	Lines []string `json:"lines"`
+	Pre   bool     `json:"pre,omitempty"`
+
+	// Raw is preformatted text as written, before tabs are expanded. It is
+	// only recorded in ParseComments mode.
+	Raw string `json:"-"`
 }
 
 func (t Text) TemplateName() string { return "text" }
 
+// Comment represents comment lines, which start with #. Comments are only
+// recorded when parsing in ParseComments mode.
+type Comment struct {
+	Lines []string `json:"lines"`
+}
+
+func (c Comment) TemplateName() string { return "comment" }
+
 // List represents a bulleted list.
 type List struct {
-	Bullet []string
+	Bullet []string `json:"bullet"`
 }
 
 func (l List) TemplateName() string { return "list" }
@@ -231,6 +287,10 @@ func (l List) TemplateName() string { return "list" }
 type Lines struct {
 	Line int // 0 indexed, so has 1-indexed number of last line returned
 	Text []string
+
+	// KeepComments records the comment lines skipped by Next in Comments.
+	KeepComments bool
+	Comments     []string
 }
 
 func (l *Lines) Next() (text string, ok bool) {
@@ -246,10 +306,20 @@ func (l *Lines) Next() (text string, ok bool) {
 			ok = true
 			break
 		}
+		if l.KeepComments {
+			l.Comments = append(l.Comments, text)
+		}
 	}
 	return
 }
 
+// TakeComments returns the comments recorded since the last call.
+func (l *Lines) TakeComments() []string {
+	c := l.Comments
+	l.Comments = nil
+	return c
+}
+
 func (l *Lines) Back() {
 	l.Line--
 }
@@ -271,6 +341,10 @@ type File struct {
 	IsDir    bool
 	Children []*File
 	Name     string
+
+	// Meta holds the header of slides and articles, it is nil for other
+	// files and for documents whose header can't be parsed.
+	Meta *Meta `json:",omitempty"`
 }
 
 func (d *File) Path() string {
@@ -0,0 +1,25 @@
+package models
+
+// SearchResult is the response of the /search endpoint.
+type SearchResult struct {
+	Query string      `json:"query"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// SearchHit is a part of a document matching a search query.
+type SearchHit struct {
+	// File is the url of the document.
+	File string `json:"file"`
+
+	// Title is the title of the document.
+	Title string `json:"title"`
+
+	// Section is the number of the matching section, it is empty when the
+	// document header matched.
+	Section      []int  `json:"section,omitempty"`
+	SectionTitle string `json:"sectionTitle,omitempty"`
+
+	// Snippet is the text surrounding the first match.
+	Snippet string `json:"snippet"`
+	Score   int    `json:"score"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimingVersion is the version of the timing file format.
+const TimingVersion = 1
+
+// MaxTicks is the maximum number of slide changes in a recording.
+const MaxTicks = 10000
+
+// Timing holds the timing recordings of a deck. It is stored as JSON in a
+// .timing file next to the deck, see TimingName.
+type Timing struct {
+	Version    int         `json:"version"`
+	Recordings []Recording `json:"recordings"`
+}
+
+// Recording is a replayable record of when the slides of a deck were shown.
+type Recording struct {
+	Name    string    `json:"name,omitempty"`
+	Created time.Time `json:"created"`
+
+	// Length is the length of the recording in milliseconds.
+	Length int64  `json:"length"`
+	Ticks  []Tick `json:"ticks"`
+}
+
+// Tick records that a slide was shown.
+type Tick struct {
+	// At is the time the slide was shown, in milliseconds since the start of
+	// the recording.
+	At int64 `json:"at"`
+
+	// Slide is the number of the slide, the title slide being 0.
+	Slide int `json:"slide"`
+}
+
+// TimingName returns the name of the timing file of the deck name.
+func TimingName(name string) string {
+	return strings.TrimSuffix(name, ".slide") + ".timing"
+}
+
+// Validate checks that the recording can be replayed on a deck with the given
+// number of slides, not counting the title slide.
+func (r *Recording) Validate(slides int) error {
+	if r.Length <= 0 {
+		return errors.New("recording has no length")
+	}
+	if len(r.Ticks) > MaxTicks {
+		return fmt.Errorf("recording has more than %d ticks", MaxTicks)
+	}
+	var last int64
+	for i, t := range r.Ticks {
+		if t.At < last {
+			return fmt.Errorf("tick %d: time %dms is out of order", i, t.At)
+		}
+		if t.At > r.Length {
+			return fmt.Errorf("tick %d: time %dms is past the end of the recording", i, t.At)
+		}
+		if t.Slide < 0 || t.Slide > slides {
+			return fmt.Errorf("tick %d: slide %d doesn't exist", i, t.Slide)
+		}
+		last = t.At
+	}
+	return nil
+}
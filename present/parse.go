@@ -11,9 +11,11 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"net/url"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -89,14 +91,36 @@ func readLines(r io.Reader) (*models.Lines, error) {
 type Context struct {
 	// ReadFile reads the file named by filename and returns the contents.
 	ReadFile func(filename string) ([]byte, error)
+
+	// Play enables runnable .play snippets, in addition to
+	// models.PlayEnabled.
+	Play bool
+
+	// NoHighlight disables the syntax highlighting of .code and .play
+	// snippets.
+	NoHighlight bool
+
+	// TrustHTML disables the sanitization policy, see SanitizeHTML and
+	// SafeURL. Files included with .html are then used as they are and urls
+	// may use any scheme. Only set it for documents from trusted authors.
+	TrustHTML bool
 }
 
 // ParseMode represents flags for the Parse function.
 type ParseMode int
 
 const (
-	// If set, parse only the title and subtitle.
+	// If set, parse only the header: the title, subtitle, time, tags and
+	// authors.
 	TitlesOnly ParseMode = 1
+
+	// If set, record recoverable problems in Doc.Diagnostics and keep
+	// parsing instead of stopping at the first error.
+	AllErrors ParseMode = 2
+
+	// If set, keep the comments and the original directive text so that the
+	// document can be printed back with Format.
+	ParseComments ParseMode = 4
 )
 
 // Parse parses a document from r.
@@ -106,6 +130,8 @@ func (ctx *Context) Parse(r io.Reader, name string, mode ParseMode) (*models.Doc
 	if err != nil {
 		return nil, err
 	}
+	errs := &errorList{name: name, all: mode&AllErrors != 0}
+	lines.KeepComments = mode&ParseComments != 0
 
 	for i := lines.Line; i < len(lines.Text); i++ {
 		if strings.HasPrefix(lines.Text[i], "*") {
@@ -117,22 +143,30 @@ func (ctx *Context) Parse(r io.Reader, name string, mode ParseMode) (*models.Doc
 		}
 	}
 
-	err = parseHeader(doc, lines)
+	err = parseHeader(doc, lines, errs)
 	if err != nil {
-		return nil, err
-	}
-	if mode&TitlesOnly != 0 {
-		return doc, nil
+		return nil, errorAt(name, lines.Line, err)
 	}
 
 	// Authors
 	if doc.Authors, err = parseAuthors(lines); err != nil {
-		return nil, err
+		return nil, errorAt(name, lines.Line, err)
 	}
+	if mode&TitlesOnly != 0 {
+		if !ctx.TrustHTML {
+			sanitize(doc)
+		}
+		return doc, nil
+	}
+	doc.Comments = lines.TakeComments()
 	// Sections
-	if doc.Sections, err = parseSections(ctx, name, lines, []int{}); err != nil {
+	if doc.Sections, err = parseSections(ctx, name, lines, []int{}, errs); err != nil {
 		return nil, err
 	}
+	doc.Diagnostics = errs.errs
+	if !ctx.TrustHTML {
+		sanitize(doc)
+	}
 	return doc, nil
 }
 
@@ -143,6 +177,33 @@ func Parse(r io.Reader, name string, mode ParseMode) (*models.Doc, error) {
 	return ctx.Parse(r, name, mode)
 }
 
+// ParseFS parses the document named name from fsys. Assets used by the
+// presentation are read from fsys as well, so name and the paths in the
+// document must be valid fs.FS paths.
+func ParseFS(fsys fs.FS, name string, mode ParseMode) (*models.Doc, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctx := Context{ReadFile: ReadFS(fsys)}
+	return ctx.Parse(f, name, mode)
+}
+
+// ReadFS returns a function suitable for Context.ReadFile that reads files
+// from fsys.
+func ReadFS(fsys fs.FS) func(filename string) ([]byte, error) {
+	return func(filename string) ([]byte, error) {
+		return fs.ReadFile(fsys, filepath.ToSlash(filename))
+	}
+}
+
+// themePrefix starts the header line selecting the code highlighting theme.
+const themePrefix = "Theme:"
+
+// isTheme matches valid theme names, they are used in css class names.
+var isTheme = regexp.MustCompile(`^[a-z0-9-]+$`)
+
 // isHeading matches any section heading.
 var isHeading = regexp.MustCompile(`^\*+ `)
 
@@ -154,7 +215,7 @@ func lesserHeading(text, prefix string) bool {
 
 // parseSections parses Sections from lines for the section level indicated by
 // number (a nil number indicates the top level).
-func parseSections(ctx *Context, name string, lines *models.Lines, number []int) ([]models.Section, error) {
+func parseSections(ctx *Context, name string, lines *models.Lines, number []int, errs *errorList) ([]models.Section, error) {
 	var sections []models.Section
 	for i := 1; ; i++ {
 		// Next non-empty line is title.
@@ -176,6 +237,8 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 		}
 		text, ok = lines.NextNonEmpty()
 		for ok && !lesserHeading(text, prefix) {
+			// Comments read so far precede this element.
+			section.Elem = appendComments(section.Elem, lines)
 			var e models.Elem
 			r, _ := utf8.DecodeRuneInString(text)
 			switch {
@@ -196,10 +259,14 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 					text, ok = lines.Next()
 				}
 				lines.Back()
-				pre := strings.Join(s, "\n")
-				pre = strings.Replace(pre, "\t", "    ", -1) // browsers treat tabs badly
+				raw := strings.TrimRightFunc(strings.Join(s, "\n"), unicode.IsSpace)
+				pre := strings.Replace(raw, "\t", "    ", -1) // browsers treat tabs badly
 				pre = strings.TrimRightFunc(pre, unicode.IsSpace)
-				e = models.Text{Lines: []string{pre}, Pre: true}
+				t := models.Text{Lines: []string{pre}, Pre: true}
+				if lines.KeepComments {
+					t.Raw = raw
+				}
+				e = t
 			case strings.HasPrefix(text, "- "):
 				var b []string
 				for ok && strings.HasPrefix(text, "- ") {
@@ -212,7 +279,7 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 				section.Notes = append(section.Notes, text[2:])
 			case strings.HasPrefix(text, prefix+"* "):
 				lines.Back()
-				subsecs, err := parseSections(ctx, name, lines, section.Number)
+				subsecs, err := parseSections(ctx, name, lines, section.Number, errs)
 				if err != nil {
 					return nil, err
 				}
@@ -222,19 +289,41 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 			case strings.HasPrefix(text, "."):
 				args := strings.Fields(text)
 				if args[0] == ".background" {
+					if len(args) != 2 {
+						if err := errs.add(lines.Line, fmt.Errorf("incorrect background invocation: %q", text)); err != nil {
+							return nil, err
+						}
+						e = unparsed(lines, text)
+						break
+					}
+					if err := ctx.checkBackground(args[1]); err != nil {
+						if err := errs.add(lines.Line, err); err != nil {
+							return nil, err
+						}
+						e = unparsed(lines, text)
+						break
+					}
 					section.Classes = append(section.Classes, "background")
 					section.Styles = append(section.Styles, "background-image: url('"+args[1]+"')")
 					break
 				}
 				parser := parsers[args[0]]
 				if parser == nil {
-					return nil, fmt.Errorf("%s:%d: unknown command %q\n", name, lines.Line, text)
+					if err := errs.add(lines.Line, fmt.Errorf("unknown command %q", text)); err != nil {
+						return nil, err
+					}
+					e = unparsed(lines, text)
+					break
 				}
 				t, err := parser(ctx, name, lines.Line, text)
 				if err != nil {
-					return nil, err
+					if err := errs.add(lines.Line, err); err != nil {
+						return nil, err
+					}
+					e = unparsed(lines, text)
+					break
 				}
-				e = t
+				e = withCmd(t, text)
 			default:
 				var l []string
 				for ok && strings.TrimSpace(text) != "" {
@@ -257,6 +346,7 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 			}
 			text, ok = lines.NextNonEmpty()
 		}
+		section.Elem = appendComments(section.Elem, lines)
 		if isHeading.MatchString(text) {
 			lines.Back()
 		}
@@ -265,7 +355,54 @@ func parseSections(ctx *Context, name string, lines *models.Lines, number []int)
 	return sections, nil
 }
 
-func parseHeader(doc *models.Doc, lines *models.Lines) error {
+// unparsed returns the element kept for a directive that can't be parsed, in
+// ParseComments mode it is a comment holding the directive text so that Format
+// prints it back as written.
+func unparsed(lines *models.Lines, text string) models.Elem {
+	if !lines.KeepComments {
+		return nil
+	}
+	return models.Comment{Lines: []string{text}}
+}
+
+// appendComments appends the comments recorded by lines, if any, to elems.
+func appendComments(elems []models.Elem, lines *models.Lines) []models.Elem {
+	if c := lines.TakeComments(); len(c) > 0 {
+		elems = append(elems, models.Comment{Lines: c})
+	}
+	return elems
+}
+
+// withCmd records the directive text on the elements created by the builtin
+// directives.
+func withCmd(e models.Elem, text string) models.Elem {
+	switch v := e.(type) {
+	case models.Code:
+		v.Cmd = text
+		return v
+	case models.Image:
+		v.Cmd = text
+		return v
+	case models.Iframe:
+		v.Cmd = text
+		return v
+	case models.Video:
+		v.Cmd = text
+		return v
+	case models.Link:
+		v.Cmd = text
+		return v
+	case models.HTML:
+		v.Cmd = text
+		return v
+	case models.Caption:
+		v.Cmd = text
+		return v
+	}
+	return e
+}
+
+func parseHeader(doc *models.Doc, lines *models.Lines, errs *errorList) error {
 	var ok bool
 	// First non-empty line starts header.
 	doc.Title, ok = lines.NextNonEmpty()
@@ -290,12 +427,20 @@ func parseHeader(doc *models.Doc, lines *models.Lines) error {
 				tags[i] = strings.TrimSpace(tags[i])
 			}
 			doc.Tags = append(doc.Tags, tags...)
+		} else if strings.HasPrefix(text, themePrefix) {
+			doc.Theme = strings.TrimSpace(text[len(themePrefix):])
+			if !isTheme.MatchString(doc.Theme) {
+				if err := errs.add(lines.Line, fmt.Errorf("invalid theme name %q", doc.Theme)); err != nil {
+					return err
+				}
+				doc.Theme = ""
+			}
 		} else if t, ok := parseTime(text); ok {
 			doc.Time = t
 		} else if doc.Subtitle == "" {
 			doc.Subtitle = text
-		} else {
-			return fmt.Errorf("unexpected header line: %q", text)
+		} else if err := errs.add(lines.Line, fmt.Errorf("unexpected header line: %q", text)); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -389,6 +534,33 @@ func parseTime(text string) (t time.Time, ok bool) {
 	return time.Time{}, false
 }
 
+// errorList collects the recoverable errors found while parsing a document.
+type errorList struct {
+	name string
+	all  bool
+	errs []models.Error
+}
+
+// add records err found at line. It returns the positioned error if parsing
+// must stop, which is the case unless all errors are collected.
+func (l *errorList) add(line int, err error) error {
+	err = errorAt(l.name, line, err)
+	if !l.all {
+		return err
+	}
+	l.errs = append(l.errs, *err.(*models.Error))
+	return nil
+}
+
+// errorAt returns err as a *models.Error positioned at line of the file name.
+// Errors that are already positioned are returned unchanged.
+func errorAt(name string, line int, err error) error {
+	if _, ok := err.(*models.Error); ok {
+		return err
+	}
+	return &models.Error{File: name, Line: line, Msg: err.Error()}
+}
+
 func isSpeakerNote(s string) bool {
 	return strings.HasPrefix(s, ": ")
 }
@@ -0,0 +1,76 @@
+package present
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+const allErrorsDoc = `Title
+Subtitle
+10 Jan 2018
+an unexpected header line
+
+Author
+
+* First
+
+.unknown directive
+
+.image
+
+.code missing.go
+
+* Second
+
+.code main.go /^func nope/
+
+Still parsed
+`
+
+func TestParseAllErrors(t *testing.T) {
+	ctx := &Context{ReadFile: func(name string) ([]byte, error) {
+		if name == "main.go" {
+			return []byte("package main\n\nfunc main() {}\n"), nil
+		}
+		return nil, os.ErrNotExist
+	}}
+
+	_, err := ctx.Parse(strings.NewReader(allErrorsDoc), "test.slide", 0)
+	if e, ok := err.(*models.Error); !ok || e.Line != 4 {
+		t.Fatalf("expected the first error at line 4, got %v", err)
+	}
+
+	doc, err := ctx.Parse(strings.NewReader(allErrorsDoc), "test.slide", AllErrors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{4, 10, 12, 14, 18}
+	if len(doc.Diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), doc.Diagnostics)
+	}
+	for i, line := range want {
+		if d := doc.Diagnostics[i]; d.File != "test.slide" || d.Line != line {
+			t.Errorf("#%d: expected diagnostic at test.slide:%d, got %v", i, line, &d)
+		}
+	}
+	if len(doc.Sections) != 2 {
+		t.Fatalf("expected 2 sections, got %d", len(doc.Sections))
+	}
+	if n := len(doc.Sections[1].Elem); n != 1 {
+		t.Errorf("expected the text after the bad .code to be parsed, got %d elements", n)
+	}
+}
+
+func TestParseFatalError(t *testing.T) {
+	ctx := &Context{ReadFile: func(string) ([]byte, error) {
+		return nil, errors.New("unexpected read")
+	}}
+	_, err := ctx.Parse(strings.NewReader("Title only"), "test.slide", AllErrors)
+	if err == nil {
+		t.Fatal("expected an error for a document without a header")
+	}
+}
@@ -0,0 +1,286 @@
+package present
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// The sanitization policy applied to documents unless Context.TrustHTML is
+// set. Files included with .html keep the allowed tags and attributes only,
+// the text of anything else is kept but the markup is dropped. Urls in links,
+// images, frames and videos must be relative or use one of the allowed
+// schemes.
+var (
+	allowedTags = set(
+		"a", "abbr", "b", "blockquote", "br", "caption", "cite", "code", "col",
+		"colgroup", "dd", "del", "details", "div", "dl", "dt", "em",
+		"figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i",
+		"img", "ins", "kbd", "li", "mark", "ol", "p", "pre", "q", "s", "samp",
+		"small", "source", "span", "strong", "sub", "summary", "sup", "table",
+		"tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul", "var", "video",
+	)
+
+	// droppedTags are removed with their content.
+	droppedTags = set("script", "style", "iframe", "object", "embed", "template", "noscript", "textarea", "select")
+
+	// voidTags have no end tag.
+	voidTags = set("br", "col", "hr", "img", "source")
+
+	allowedAttrs = set("class", "id", "title", "lang", "dir")
+
+	tagAttrs = map[string]map[string]bool{
+		"a":          set("href", "name", "target"),
+		"blockquote": set("cite"),
+		"col":        set("span"),
+		"colgroup":   set("span"),
+		"img":        set("src", "alt", "width", "height"),
+		"ol":         set("start", "type"),
+		"q":          set("cite"),
+		"source":     set("src", "type"),
+		"td":         set("colspan", "rowspan", "align"),
+		"th":         set("colspan", "rowspan", "align", "scope"),
+		"video":      set("src", "poster", "width", "height", "controls", "loop", "muted"),
+	}
+
+	urlAttrs = set("href", "src", "cite", "poster")
+
+	allowedSchemes = set("http", "https", "mailto")
+)
+
+func set(s ...string) map[string]bool {
+	m := make(map[string]bool, len(s))
+	for _, v := range s {
+		m[v] = true
+	}
+	return m
+}
+
+// SafeURL returns true if u is relative or uses one of the schemes allowed by
+// the sanitization policy. Urls with control characters are rejected, browsers
+// ignore them so they could hide a scheme.
+func SafeURL(u string) bool {
+	v, err := url.Parse(strings.TrimSpace(u))
+	if err != nil {
+		return false
+	}
+	return v.Scheme == "" || allowedSchemes[strings.ToLower(v.Scheme)]
+}
+
+// checkURL returns an error if u is not allowed in the documents parsed by
+// ctx.
+func (ctx *Context) checkURL(u string) error {
+	if ctx.TrustHTML || SafeURL(u) {
+		return nil
+	}
+	return fmt.Errorf("url %q is not allowed, use http, https, mailto or a relative url", u)
+}
+
+// checkBackground returns an error if u can't be the background image of the
+// slides parsed by ctx.
+func (ctx *Context) checkBackground(u string) error {
+	if err := ctx.checkURL(u); err != nil {
+		return err
+	}
+	if !ctx.TrustHTML && strings.ContainsAny(u, `'"()\`) {
+		// Quotes and parentheses would end the css url.
+		return fmt.Errorf("url %q is not allowed in .background", u)
+	}
+	return nil
+}
+
+// SanitizeHTML returns s with the markup not allowed by the sanitization
+// policy removed. Text is kept and escaped. Markup that can't be parsed is
+// kept as text too.
+func SanitizeHTML(s string) template.HTML {
+	var b strings.Builder
+	d := xml.NewDecoder(strings.NewReader(s))
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	// open are the elements written and not closed yet, elements that are
+	// left out have an empty name.
+	var open []string
+	// skip is the depth in an element dropped with its content.
+	skip := 0
+	for {
+		off := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if skip == 0 {
+				b.WriteString(html.EscapeString(s[off:]))
+			}
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if skip > 0 || droppedTags[name] {
+				skip++
+				continue
+			}
+			if t.Name.Space != "" || !allowedTags[name] {
+				open = append(open, "")
+				continue
+			}
+			open = append(open, name)
+			writeStart(&b, name, t.Attr)
+		case xml.EndElement:
+			if skip > 0 {
+				skip--
+				continue
+			}
+			if len(open) == 0 {
+				continue
+			}
+			name := open[len(open)-1]
+			open = open[:len(open)-1]
+			if name != "" && !voidTags[name] {
+				b.WriteString("</" + name + ">")
+			}
+		case xml.CharData:
+			if skip == 0 {
+				b.WriteString(html.EscapeString(string(t)))
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		if name := open[i]; name != "" && !voidTags[name] {
+			b.WriteString("</" + name + ">")
+		}
+	}
+	return template.HTML(b.String())
+}
+
+// writeStart writes the start tag of the element name with the allowed
+// attributes of attrs.
+func writeStart(b *strings.Builder, name string, attrs []xml.Attr) {
+	b.WriteString("<" + name)
+	blank := false
+	for _, a := range attrs {
+		key := strings.ToLower(a.Name.Local)
+		if a.Name.Space != "" || !(allowedAttrs[key] || tagAttrs[name][key]) {
+			continue
+		}
+		if urlAttrs[key] && !SafeURL(a.Value) {
+			continue
+		}
+		if key == "target" && a.Value == "_blank" {
+			blank = true
+		}
+		b.WriteString(" " + key + `="` + html.EscapeString(a.Value) + `"`)
+	}
+	if blank {
+		// Pages opened by links must not get a handle on the deck.
+		b.WriteString(` rel="noopener noreferrer"`)
+	}
+	b.WriteString(">")
+}
+
+// sanitizeLinks returns s with the inline links to urls that are not allowed
+// replaced by their label. Links are found the way Style finds them, in the
+// escaped text, and replacing a link can form a new one, so s is sanitized
+// until it doesn't change.
+func sanitizeLinks(s string) string {
+	for {
+		t := replaceUnsafeLinks(s)
+		if t == s {
+			return s
+		}
+		s = t
+	}
+}
+
+func replaceUnsafeLinks(s string) string {
+	var b strings.Builder
+	for _, word := range split(html.EscapeString(s)) {
+		// Escaping doesn't change the words, entities hold no spaces or
+		// brackets.
+		if _, n := parseInlineLink(word); n == 0 {
+			b.WriteString(html.UnescapeString(word))
+			continue
+		}
+		urlEnd := strings.Index(word, "]")
+		raw := html.UnescapeString(word[2:urlEnd])
+		switch {
+		case SafeURL(raw):
+			b.WriteString(html.UnescapeString(word))
+		case urlEnd < len(word)-2:
+			// [[url][label]], the label starts after "][".
+			b.WriteString(html.UnescapeString(word[urlEnd+2 : len(word)-2]))
+		default:
+			b.WriteString(raw)
+		}
+	}
+	return b.String()
+}
+
+// sanitizeElems applies the sanitization policy to the inline links of elems
+// and to links that were not written as directives, like those of authors.
+func sanitizeElems(elems []models.Elem) []models.Elem {
+	out := elems[:0]
+	for _, e := range elems {
+		switch v := e.(type) {
+		case models.Text:
+			if !v.Pre {
+				for i := range v.Lines {
+					v.Lines[i] = sanitizeLinks(v.Lines[i])
+				}
+			}
+			e = v
+		case models.List:
+			for i := range v.Bullet {
+				v.Bullet[i] = sanitizeLinks(v.Bullet[i])
+			}
+			e = v
+		case models.Caption:
+			v.Text = sanitizeLinks(v.Text)
+			e = v
+		case models.Link:
+			if v.URL != nil && !SafeURL(v.URL.String()) {
+				e = models.Text{Lines: []string{v.Label}}
+			}
+		case models.Section:
+			v = sanitizeSection(v)
+			e = v
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// sanitizeSection applies the sanitization policy to the elements and the
+// presenter notes of s.
+func sanitizeSection(s models.Section) models.Section {
+	s.Elem = sanitizeElems(s.Elem)
+	sanitizeNotes(s.Notes)
+	return s
+}
+
+// sanitizeNotes replaces the inline links to urls that are not allowed in
+// notes, they are styled like text.
+func sanitizeNotes(notes []string) {
+	for i := range notes {
+		notes[i] = sanitizeLinks(notes[i])
+	}
+}
+
+// sanitize applies the sanitization policy to doc.
+func sanitize(doc *models.Doc) {
+	sanitizeNotes(doc.TitleNotes)
+	for i := range doc.Authors {
+		doc.Authors[i].Elem = sanitizeElems(doc.Authors[i].Elem)
+	}
+	for i := range doc.Sections {
+		doc.Sections[i] = sanitizeSection(doc.Sections[i])
+	}
+}
@@ -0,0 +1,137 @@
+package present
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+func TestSanitizeHTML(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{`<p class="x">Hello <b>world</b></p>`, `<p class="x">Hello <b>world</b></p>`},
+		{`<P CLASS="x">caps</P>`, `<p class="x">caps</p>`},
+		{`<script>alert(1)</script>after`, `after`},
+		{`<style>p { color: red }</style><p>text</p>`, `<p>text</p>`},
+		{`<div onclick="alert(1)" style="x">hi</div>`, `<div>hi</div>`},
+		{`<a href="javascript:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="java&#x09;script:alert(1)">x</a>`, `<a>x</a>`},
+		{`<a href="https://golang.org" target="_blank">go</a>`, `<a href="https://golang.org" target="_blank" rel="noopener noreferrer">go</a>`},
+		{`<img src="gopher.png" onerror="alert(1)"><br>next`, `<img src="gopher.png"><br>next`},
+		{`<img src="data:image/png;base64,AAAA">`, `<img>`},
+		{`<custom>kept <i>text</i></custom>`, `kept <i>text</i>`},
+		{`<svg:a xlink:href="#">x</svg:a>`, `x`},
+		{`<!-- comment --><p>a &amp; b &lt; &nbsp;c</p>`, "<p>a &amp; b &lt;  c</p>"},
+		{`<ul><li>one<li>two</ul>`, `<ul><li>one<li>two</li></li></ul>`},
+		{`<p>unclosed`, `<p>unclosed</p>`},
+		{`a < b <script>`, `a &lt; b &lt;script&gt;`},
+		{`<p title='">'>q</p>`, `<p title="&#34;&gt;">q</p>`},
+		// Markup that can't be parsed is escaped.
+		{`<p title='<script>'>q</p>`, `&lt;p title=&#39;&lt;script&gt;&#39;&gt;q&lt;/p&gt;`},
+	}
+	for _, tt := range tests {
+		if got := SanitizeHTML(tt.in); got != template.HTML(tt.out) {
+			t.Errorf("%q: expected\n%q got\n%q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestSanitizeLinks(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{"see [[https://golang.org][Go]]", "see [[https://golang.org][Go]]"},
+		{"see [[javascript:alert(1)][this]] now", "see this now"},
+		{"[[JavaScript:alert(1)]] and [[data:text/html,x][y]]", "JavaScript:alert(1) and y"},
+		{"[[mailto:a@b.c]] [[doc.html]]", "[[mailto:a@b.c]] [[doc.html]]"},
+		{"not [[a link", "not [[a link"},
+		{"see [[a [[javascript:alert(1)]] here", "see [[a javascript:alert(1) here"},
+		{`[[javascript:alert("x")][quoted]]`, "quoted"},
+		{"[[javascript:x][[[javascript:y]]]]", "javascript:y"},
+		{"a & [[https://golang.org/?a=1&b=2][<Go>]]", "a & [[https://golang.org/?a=1&b=2][<Go>]]"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeLinks(tt.in); got != tt.out {
+			t.Errorf("%q: expected %q got %q", tt.in, tt.out, got)
+		}
+		if got := Style(tt.out); strings.Contains(string(got), `href="javascript:`) {
+			t.Errorf("%q: expected no unsafe link got %s", tt.in, got)
+		}
+	}
+}
+
+func TestParseTrustHTML(t *testing.T) {
+	files := map[string]string{
+		"raw.html": `<p onclick="x()">raw</p><script>alert(1)</script>`,
+	}
+	read := func(name string) ([]byte, error) {
+		return []byte(files[name]), nil
+	}
+	const doc = `Title
+: title note [[javascript:alert(1)][x]]
+
+Author
+javascript:alert(1)
+
+* Section
+
+Go [[javascript:alert(1)][there]].
+
+.html raw.html
+
+: note [[javascript:alert(2)][y]]
+
+** Sub
+
+: sub note [[javascript:alert(3)]]
+`
+	ctx := &Context{ReadFile: read}
+	d, err := ctx.Parse(strings.NewReader(doc), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l, ok := d.Authors[0].Elem[1].(models.Link); ok {
+		t.Errorf("expected the author link to become text got %v", l.URL)
+	}
+	if got := d.Sections[0].Elem[0].(models.Text).Lines[0]; got != "Go there." {
+		t.Errorf("expected the inline link to be replaced by its label got %q", got)
+	}
+	notes := []string{d.TitleNotes[0], d.Sections[0].Notes[0], d.Sections[0].Elem[2].(models.Section).Notes[0]}
+	want := []string{"title note x", "note y", "sub note javascript:alert(3)"}
+	for i := range notes {
+		if notes[i] != want[i] {
+			t.Errorf("expected the links of notes to be replaced, want %q got %q", want[i], notes[i])
+		}
+	}
+	if got := d.Sections[0].Elem[1].(models.HTML).HTML; got != "<p>raw</p>" {
+		t.Errorf("expected sanitized html got %q", got)
+	}
+
+	for _, line := range []string{
+		".link javascript:alert(1) click",
+		".image javascript:alert(1)",
+		".iframe data:text/html,x",
+		".video vbscript:x video/mp4",
+		".background x');color:red",
+	} {
+		_, err := ctx.Parse(strings.NewReader("Title\n\n* S\n\n"+line+"\n"), "doc.slide", 0)
+		if err == nil || !strings.Contains(err.Error(), "is not allowed") {
+			t.Errorf("%s: expected the url to be rejected got %v", line, err)
+		}
+	}
+
+	ctx.TrustHTML = true
+	d, err = ctx.Parse(strings.NewReader(doc), "doc.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Sections[0].Elem[1].(models.HTML).HTML; got != template.HTML(files["raw.html"]) {
+		t.Errorf("expected raw html when trusted got %q", got)
+	}
+	if got := d.Sections[0].Elem[0].(models.Text).Lines[0]; !strings.Contains(got, "[[javascript:") {
+		t.Errorf("expected the inline link to be kept when trusted got %q", got)
+	}
+}
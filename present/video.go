@@ -17,6 +17,12 @@ func init() {
 
 func parseVideo(ctx *Context, fileName string, lineno int, text string) (models.Elem, error) {
 	args := strings.Fields(text)
+	if len(args) < 3 {
+		return nil, fmt.Errorf("incorrect video invocation: %q", text)
+	}
+	if err := ctx.checkURL(args[1]); err != nil {
+		return nil, err
+	}
 	vid := models.Video{URL: args[1], SourceType: args[2]}
 	a, err := parseArgs(fileName, lineno, args[3:])
 	if err != nil {
@@ -0,0 +1,215 @@
+// Package render renders documents to HTML without JavaScript. The markup is
+// the same the vecty components in ui produce, so pages are styled by the same
+// sheets and the frontend can take over once it is loaded.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// Options configures the rendering of a document.
+type Options struct {
+	// Dir is the url path of the directory holding the document, relative
+	// image urls are resolved against it.
+	Dir string
+
+	// Active is the slide shown first, 0 for the title slide.
+	Active int
+}
+
+// Slides writes the body of the page presenting doc as slides.
+func Slides(w io.Writer, doc *models.Doc, opts Options) error {
+	return tmpl.ExecuteTemplate(w, "slides", &page{Doc: doc, opts: opts})
+}
+
+// Article writes the body of the page rendering doc as an article.
+func Article(w io.Writer, doc *models.Doc, opts Options) error {
+	return tmpl.ExecuteTemplate(w, "article", &page{Doc: doc, opts: opts})
+}
+
+// page is the data of the templates.
+type page struct {
+	*models.Doc
+	opts Options
+}
+
+// Pos returns the position class of slide n, as set by the slide component.
+func (p *page) Pos(n int) string {
+	switch n - p.opts.Active {
+	case -2:
+		return "far-past"
+	case -1:
+		return "past"
+	case 0:
+		return "current"
+	case 1:
+		return "next"
+	case 2:
+		return "far-next"
+	default:
+		return ""
+	}
+}
+
+// Elem renders a document element, elements the frontend doesn't render, like
+// comments, are left out.
+func (p *page) Elem(e models.Elem) (template.HTML, error) {
+	var name string
+	var data interface{} = e
+	switch v := e.(type) {
+	case models.Section:
+		name, data = "section", &section{P: p, S: v}
+	case models.List, models.Text, models.Code, models.Link, models.Caption:
+		name = e.TemplateName()
+	case models.HTML:
+		name = "html"
+	case models.Image:
+		name, data = "image", &media{Elem: v, Src: p.src(v.URL), Width: v.Width, Height: v.Height}
+	case models.Iframe:
+		name, data = "iframe", &media{Elem: v, Src: p.src(v.URL), Width: v.Width, Height: v.Height}
+	case models.Video:
+		name, data = "video", &media{Elem: v, Src: p.src(v.URL), Width: v.Width, Height: v.Height}
+	default:
+		return "", nil
+	}
+	var b bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
+
+// src resolves the relative url u against the directory of the document.
+func (p *page) src(u string) string {
+	v, err := url.Parse(u)
+	if err != nil || v.IsAbs() || strings.HasPrefix(u, "/") {
+		return u
+	}
+	return path.Join(p.opts.Dir, u)
+}
+
+// section is a section rendered in an article.
+type section struct {
+	P *page
+	S models.Section
+}
+
+// Heading returns the heading of the section, it is empty for sections too
+// deep to have one.
+func (s *section) Heading() template.HTML {
+	n := len(s.S.Number)
+	if n > 5 {
+		return ""
+	}
+	return template.HTML(fmt.Sprintf("<h%d id=\"%s\">%s</h%d>", n,
+		template.HTMLEscapeString("TOC_"+s.S.FormattedNumber()),
+		template.HTMLEscapeString(s.S.FormattedNumber()+"  "+s.S.Title), n))
+}
+
+// media is an image, frame or video with its url resolved.
+type media struct {
+	Elem          models.Elem
+	Src           string
+	Width, Height int
+}
+
+var tmpl = template.Must(template.New("render").Funcs(template.FuncMap{
+	"style": models.Style,
+	"lines": func(lines []string) template.HTML {
+		s := make([]string, len(lines))
+		for i, l := range lines {
+			s[i] = string(models.Style(l))
+		}
+		return template.HTML(strings.Join(s, "<br>"))
+	},
+	"join":  strings.Join,
+	"add":   func(a, b int) int { return a + b },
+	"time":  func(p *page) string { return p.Time.Format(models.TimeFormat) },
+	"tocID": func(s models.Section) string { return "TOC_" + s.FormattedNumber() },
+}).Parse(templates))
+
+const templates = `
+{{- define "slides" -}}
+<section class="slides layout-widescreen {{.ThemeClass}}">
+<article class="{{.Pos 0}}">
+<h1>{{.Title}}</h1>
+{{- if .Subtitle}}<h3>{{.Subtitle}}</h3>{{end}}
+{{- if not .Time.IsZero}}<h3>{{time .}}</h3>{{end}}
+{{- range .Authors}}<div class="presenter">{{range .Elem}}{{$.Elem .}}{{end}}</div>{{end}}
+</article>
+{{- range $i, $s := .Sections}}
+<article class="{{$.Pos (add $i 1)}}{{range $s.Classes}} {{.}}{{end}}"{{if $s.Styles}} style="{{join $s.Styles " "}}"{{end}}>
+{{- if $s.Elem}}<h3>{{$s.Title}}</h3>{{range $s.Elem}}{{$.Elem .}}{{end}}
+{{- else}}<h2>{{$s.Title}}</h2>{{end -}}
+</article>
+{{- end}}
+</section>
+{{- end}}
+
+{{- define "article" -}}
+<div class="wide" id="topbar"><div class="container"><div id="heading">
+{{- range .Authors}}<div class="author">{{range .Elem}}{{$.Elem .}}{{end}}</div>{{end -}}
+</div></div></div>
+<div class="wide {{.ThemeClass}}" id="page"><div class="container">
+{{- if .Sections}}
+<div id="toc" class="no-print"><div id="tochead">Contents</div><ul class="toc-outer">
+{{- range .Sections}}<li><a href="#{{tocID .}}">{{.Title}}</a></li>{{end -}}
+</ul></div>
+{{- end}}
+{{- range .Sections}}{{$.Elem .}}{{end}}
+</div></div>
+{{- end}}
+
+{{- define "section" -}}
+<div>{{.Heading}}{{range .S.Elem}}{{$.P.Elem .}}{{end}}</div>
+{{- end}}
+
+{{- define "list" -}}
+<ul>{{range .Bullet}}<li>{{style .}}</li>{{end}}</ul>
+{{- end}}
+
+{{- define "text" -}}
+{{if .Pre}}<div class="code"><pre>{{join .Lines "\n"}}</pre></div>
+{{- else}}<p>{{lines .Lines}}</p>{{end}}
+{{- end}}
+
+{{- define "code" -}}
+<div class="code{{if .Play}} playground{{end}}"{{if .Edit}} contenteditable="true" spellcheck="false"{{end}}>{{.Text}}</div>
+{{- end}}
+
+{{- define "size" -}}
+{{if .Height}} height="{{.Height}}"{{end}}{{if .Width}} width="{{.Width}}"{{end}}
+{{- end}}
+
+{{- define "image" -}}
+<div class="image"><img src="{{.Src}}"{{template "size" .}}></div>
+{{- end}}
+
+{{- define "html" -}}
+<div>{{.HTML}}</div>
+{{- end}}
+
+{{- define "iframe" -}}
+<iframe src="{{.Src}}"{{template "size" .}}></iframe>
+{{- end}}
+
+{{- define "video" -}}
+<div class="video"><video{{template "size" .}} controls><source src="{{.Src}}" type="{{.Elem.SourceType}}"></video></div>
+{{- end}}
+
+{{- define "link" -}}
+<p class="link"><a href="{{.URL}}" target="_blank">{{.Label}}</a></p>
+{{- end}}
+
+{{- define "caption" -}}
+<figcaption>{{.Text}}</figcaption>
+{{- end}}
+`
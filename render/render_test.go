@@ -0,0 +1,109 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+)
+
+const testDoc = `Title
+Subtitle
+2 Jan 2015
+
+Author Name
+
+* First
+
+Some *bold* text
+on two lines
+
+- one
+- two
+
+.image gopher.png 100 200
+
+* Second
+
+** Sub
+
+	pre <formatted>
+`
+
+func TestRender(t *testing.T) {
+	doc, err := present.Parse(strings.NewReader(testDoc), "test.slide", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name   string
+		render func(*bytes.Buffer) error
+		want   []string
+	}{
+		{"slides", func(b *bytes.Buffer) error {
+			return Slides(b, doc, Options{Dir: "/talks", Active: 1})
+		}, []string{
+			`<section class="slides layout-widescreen theme-default">`,
+			`<article class="past">` + "\n" + `<h1>Title</h1><h3>Subtitle</h3><h3>2 January 2015</h3><div class="presenter"><p>Author Name</p></div>`,
+			`<article class="current"><h3>First</h3><p>Some <b>bold</b> text<br>on two lines</p><ul><li>one</li><li>two</li></ul>`,
+			`<div class="image"><img src="/talks/gopher.png" height="100" width="200"></div>`,
+			`<article class="next"><h3>Second</h3><div><h2 id="TOC_2.1.">2.1.  Sub</h2><div class="code"><pre>pre &lt;formatted&gt;</pre></div></div></article>`,
+		}},
+		{"article", func(b *bytes.Buffer) error {
+			return Article(b, doc, Options{})
+		}, []string{
+			`<div id="heading"><div class="author"><p>Author Name</p></div></div>`,
+			`<ul class="toc-outer"><li><a href="#TOC_1.">First</a></li><li><a href="#TOC_2.">Second</a></li></ul>`,
+			`<div><h1 id="TOC_1.">1.  First</h1><p>Some <b>bold</b> text<br>on two lines</p>`,
+			`<img src="gopher.png" height="100" width="200">`,
+		}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := tt.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%s: expected %q in\n%s", tt.name, want, b.String())
+			}
+		}
+	}
+}
+
+func TestRenderMedia(t *testing.T) {
+	doc := &models.Doc{
+		Title: "Media",
+		Sections: []models.Section{{
+			Number: []int{1},
+			Title:  "Embeds",
+			Elem: []models.Elem{
+				models.HTML{HTML: "<p class=\"raw\">raw</p>"},
+				models.Iframe{URL: "https://example.com/embed", Width: 640, Height: 480},
+				models.Video{URL: "clip.mp4", SourceType: "video/mp4", Width: 320},
+			},
+		}},
+	}
+	want := []string{
+		`<div><p class="raw">raw</p></div>`,
+		`<iframe src="https://example.com/embed" height="480" width="640"></iframe>`,
+		`<div class="video"><video width="320" controls><source src="/talks/clip.mp4" type="video/mp4"></video></div>`,
+	}
+	for name, render := range map[string]func(io.Writer, *models.Doc, Options) error{
+		"slides":  Slides,
+		"article": Article,
+	} {
+		var b bytes.Buffer
+		if err := render(&b, doc, Options{Dir: "/talks"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(b.String(), w) {
+				t.Errorf("%s: expected %q in\n%s", name, w, b.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gernest/vectypresent/data"
+	"github.com/gernest/vectypresent/pdf"
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/render"
+	"github.com/urfave/cli"
+)
+
+// ExportCommand returns the command that renders a directory to a static site.
+func ExportCommand() cli.Command {
+	return cli.Command{
+		Name:      "export",
+		Usage:     "write a static site that can be hosted without the server",
+		ArgsUsage: "<dir> <out>, or --format pdf <deck.slide> [<out.pdf>]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "url",
+				Usage: "absolute url the site is hosted at, used by the feeds and the sitemap",
+			},
+			cli.BoolFlag{
+				Name:  "trust-html",
+				Usage: "use .html includes and urls as written instead of sanitizing them",
+			},
+			cli.StringFlag{
+				Name:  "format",
+				Usage: "html for a static site of a directory, pdf for a document",
+				Value: "html",
+			},
+			cli.BoolFlag{
+				Name:  "notes",
+				Usage: "keep the presenter notes, they are left out by default",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			return Export(ctx.Args().Get(0), ctx.Args().Get(1), ExportOptions{
+				URL:       ctx.String("url"),
+				TrustHTML: ctx.Bool("trust-html"),
+				Format:    ctx.String("format"),
+				Notes:     ctx.Bool("notes"),
+			})
+		},
+	}
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// URL is the absolute url the site is hosted at. Feeds and the sitemap
+	// need absolute links, they link relative to the host root when URL is
+	// empty.
+	URL string
+
+	// TrustHTML disables the sanitization of documents, see
+	// present.Context.TrustHTML.
+	TrustHTML bool
+
+	// Format is "html", the default, to export a directory as a static site
+	// or "pdf" to export a single document as a PDF file.
+	Format string
+
+	// Notes keeps the presenter notes. They are stripped from the exported
+	// documents and the sources of documents are left out otherwise. PDF
+	// files get a page with the notes after each slide that has some.
+	Notes bool
+}
+
+// Export renders the directory src into out as a self contained static site.
+//
+// The layout mirrors the urls used by the server, with pages written as html
+// files so they can be served from plain object storage:
+//
+//	index.html                 the root directory page
+//	<dir>/index.html           directory pages
+//	<file>.slide.html          slide and article pages
+//	tags/<tag>/index.html      tag pages
+//	<file>.slide               raw files, as found in src, documents only
+//	                           when notes are kept
+//	files/<file>.slide         parsed documents, as served on /files/
+//	timings/<file>.slide       timing recordings, as served on /timings/
+//	context                    the directory tree, as served on /context
+//	feed.atom                  the feed of all the articles
+//	feeds/<dir>.atom           the feeds of the articles in each directory
+//	sitemap.xml                the sitemap of all the pages
+//	static/                    the embedded assets
+//
+// All urls in the pages are relative, so the site works when hosted under a
+// sub path.
+func Export(src, out string, opts ExportOptions) error {
+	switch opts.Format {
+	case "", "html":
+	case "pdf":
+		return ExportPDF(src, out, opts)
+	default:
+		return fmt.Errorf("unknown export format %q, expected html or pdf", opts.Format)
+	}
+	if src == "" || out == "" {
+		return errors.New("usage: export <dir> <out>")
+	}
+	src = filepath.Clean(src)
+	fsys, root := os.DirFS(filepath.Dir(src)), filepath.Base(src)
+	t, err := template.New("index.html").Parse(indexTpl)
+	if err != nil {
+		return err
+	}
+	dirDoc, err := LoadFS(fsys, root)
+	if err != nil {
+		return err
+	}
+	base := opts.URL
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	e := &exporter{
+		fsys:  fsys,
+		out:   out,
+		t:     t,
+		site:  site{fsys: fsys, base: base, static: true},
+		trust: opts.TrustHTML,
+		notes: opts.Notes,
+	}
+	if err := e.copyTree(root); err != nil {
+		return err
+	}
+	if err := e.writePage("/", dirDoc, dirSheet); err != nil {
+		return err
+	}
+	if err := e.export(dirDoc); err != nil {
+		return err
+	}
+	if err := e.writePage("/tags", tagPage(""), dirSheet); err != nil {
+		return err
+	}
+	for _, tag := range dirDoc.Tags() {
+		if !validTagName(tag) {
+			continue
+		}
+		if err := e.writePage("/tags/"+tag, tagPage(tag), dirSheet); err != nil {
+			return err
+		}
+	}
+	if err := e.create("/context", func(w io.Writer) error {
+		return WriteJson(w, dirDoc)
+	}); err != nil {
+		return err
+	}
+	if err := e.create("/feed.atom", func(w io.Writer) error {
+		return e.site.writeFeed(w, dirDoc, "/feed.atom")
+	}); err != nil {
+		return err
+	}
+	if err := e.create("/sitemap.xml", func(w io.Writer) error {
+		return e.site.writeSitemap(w, dirDoc)
+	}); err != nil {
+		return err
+	}
+	for _, name := range data.AssetNames() {
+		b, err := data.Asset(name)
+		if err != nil {
+			return err
+		}
+		if err := e.create("/static/"+name, func(w io.Writer) error {
+			_, err := w.Write(b)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportPDF writes the document src to out as a PDF file, with a page per slide.
+// out defaults to src with the extension replaced by .pdf. Images are read
+// from the directory of src, images on other hosts are left out.
+func ExportPDF(src, out string, opts ExportOptions) error {
+	if src == "" {
+		return errors.New("usage: export --format pdf <deck.slide> [<out.pdf>]")
+	}
+	if out == "" {
+		out = strings.TrimSuffix(src, filepath.Ext(src)) + ".pdf"
+	}
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ctx := present.Context{ReadFile: ioutil.ReadFile, NoHighlight: true, TrustHTML: opts.TrustHTML}
+	doc, err := ctx.Parse(f, src, 0)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	err = pdf.Write(&b, doc, pdf.Options{
+		Notes: opts.Notes,
+		ReadFile: func(name string) ([]byte, error) {
+			return ioutil.ReadFile(filepath.Join(filepath.Dir(src), filepath.FromSlash(name)))
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(out, b.Bytes(), 0644)
+}
+
+type exporter struct {
+	fsys  fs.FS
+	out   string
+	t     *template.Template
+	site  site
+	trust bool
+	notes bool
+}
+
+// parse parses the document d, without its presenter notes unless they are
+// kept.
+func (e *exporter) parse(d *models.File) (*models.Doc, error) {
+	f, err := e.fsys.Open(d.Path())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctx := present.Context{ReadFile: present.ReadFS(e.fsys), TrustHTML: e.trust}
+	doc, err := ctx.Parse(f, d.Path(), 0)
+	if err != nil {
+		return nil, err
+	}
+	if !e.notes {
+		stripNotes(doc)
+	}
+	return doc, nil
+}
+
+// export writes the pages and parsed documents for d and all its children.
+func (e *exporter) export(d *models.File) error {
+	switch {
+	case d.IsDir:
+		if err := e.writePage(d.URL(), d, dirSheet); err != nil {
+			return err
+		}
+		feed := feedPath(d)
+		if err := e.create(feed, func(w io.Writer) error {
+			return e.site.writeFeed(w, d, feed)
+		}); err != nil {
+			return err
+		}
+		for _, child := range d.Children {
+			if err := e.export(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case d.IsSlide(), d.IsArticle():
+		sheet := slideSheet
+		if d.IsArticle() {
+			sheet = articleSheet
+		}
+		doc, err := e.parse(d)
+		if err != nil {
+			return err
+		}
+		data := index(d, sheet, relativeBase(pagePath(d.URL())))
+		if err := prerender(data, d, doc, render.Options{}); err != nil {
+			return err
+		}
+		if err := e.writeIndex(d.URL(), data); err != nil {
+			return err
+		}
+		if err := e.create("/files"+d.URL(), func(w io.Writer) error {
+			return models.Encode(w, doc)
+		}); err != nil {
+			return err
+		}
+		if !d.IsSlide() {
+			return nil
+		}
+		// Recordings are served on /timings/ so they can be replayed.
+		timing := models.TimingName(d.Path())
+		if _, err := fs.Stat(e.fsys, timing); err != nil {
+			return nil
+		}
+		return e.copyFile(timing, "/timings"+d.URL())
+	default:
+		return e.copyFile(d.Path(), "/files"+d.URL())
+	}
+}
+
+// writePage renders the index page for the file at url.
+func (e *exporter) writePage(url string, doc interface{}, sheet string) error {
+	return e.writeIndex(url, index(doc, sheet, relativeBase(pagePath(url))))
+}
+
+// writeIndex writes the index page for the file at url with the template data.
+func (e *exporter) writeIndex(url string, data map[string]interface{}) error {
+	data["static"] = true
+	return e.create(pagePath(url), func(w io.Writer) error {
+		return e.t.ExecuteTemplate(w, "index.html", data)
+	})
+}
+
+// copyTree copies every non hidden file under dir to the output directory, so
+// images and other assets referenced by the documents resolve. Unless the root
+// is trusted html files are left out, static hosts can't sandbox them, and the
+// sources of documents are left out unless notes are kept.
+func (e *exporter) copyTree(dir string) error {
+	return fs.WalkDir(e.fsys, dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !e.trust && isHTML(p) || !e.notes && hasDocument([]string{p}) {
+			return nil
+		}
+		return e.copyFile(p, "/"+p)
+	})
+}
+
+// isHTML returns true if browsers render the file name as a page.
+func isHTML(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".html", ".htm", ".xhtml", ".svg":
+		return true
+	}
+	return false
+}
+
+// copyFile copies the file name in the source file system to url.
+func (e *exporter) copyFile(name, url string) error {
+	b, err := fs.ReadFile(e.fsys, name)
+	if err != nil {
+		return err
+	}
+	return e.create(url, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	})
+}
+
+// create creates the output file for url and calls fn to write its contents.
+func (e *exporter) create(url string, fn func(io.Writer) error) error {
+	name := filepath.Join(e.out, filepath.FromSlash(url))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pagePath returns the path of the html page rendering the file at url in an
+// exported site.
+func pagePath(url string) string {
+	switch path.Ext(url) {
+	case ".slide", ".article":
+		return url + ".html"
+	default:
+		return path.Join(url, "index.html")
+	}
+}
+
+// validTagName returns true if tag can name the directory of its page in an
+// exported site.
+func validTagName(tag string) bool {
+	return !strings.Contains(tag, "/") && tag != "." && tag != ".."
+}
+
+// relativeBase returns the relative path from the page at url to the root of
+// the exported site.
+func relativeBase(url string) string {
+	n := strings.Count(strings.TrimPrefix(url, "/"), "/")
+	if n == 0 {
+		return "./"
+	}
+	return strings.Repeat("../", n)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportPaths(t *testing.T) {
+	var tests = []struct {
+		url, page, base string
+	}{
+		{"/", "/index.html", "./"},
+		{"/talks", "/talks/index.html", "../"},
+		{"/talks/sub", "/talks/sub/index.html", "../../"},
+		{"/talks/test.slide", "/talks/test.slide.html", "../"},
+		{"/talks/sub/doc.article", "/talks/sub/doc.article.html", "../../"},
+		{"/tags/go", "/tags/go/index.html", "../../"},
+	}
+	for _, tt := range tests {
+		page := pagePath(tt.url)
+		if page != tt.page {
+			t.Errorf("%s: expected the page %s got %s", tt.url, tt.page, page)
+		}
+		if base := relativeBase(page); base != tt.base {
+			t.Errorf("%s: expected the base %s got %s", tt.url, tt.base, base)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"test.slide":      testSlide,
+		"gopher.png":      "png",
+		"raw.html":        "<script>alert(1)</script>",
+		".secret":         "secret",
+		".git/config":     "config",
+		"sub/doc.article": "Title\nTags: go\n\n* Section\n\nText\n",
+		"sub/image.svg":   "<svg></svg>",
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, "talks", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, trust := range []bool{false, true} {
+		out := filepath.Join(dir, "out")
+		os.RemoveAll(out)
+		if err := Export(filepath.Join(dir, "talks"), out, ExportOptions{TrustHTML: trust}); err != nil {
+			t.Fatal(err)
+		}
+		exists := func(name string) bool {
+			_, err := os.Stat(filepath.Join(out, filepath.FromSlash(name)))
+			return err == nil
+		}
+		for _, name := range []string{
+			"index.html",
+			"talks/index.html",
+			"talks/test.slide.html",
+			"talks/sub/index.html",
+			"talks/sub/doc.article.html",
+			"talks/gopher.png",
+			"files/talks/test.slide",
+			"files/talks/sub/doc.article",
+			"tags/go/index.html",
+			"context",
+			"feed.atom",
+			"feeds/talks/sub.atom",
+			"sitemap.xml",
+			"static/ui.js",
+		} {
+			if !exists(name) {
+				t.Errorf("trust %v: expected %s to be written", trust, name)
+			}
+		}
+		// Hidden files and the sources of documents are left out, html
+		// files unless the root is trusted.
+		for _, name := range []string{"talks/.secret", "talks/.git/config", "talks/test.slide", "talks/sub/doc.article"} {
+			if exists(name) {
+				t.Errorf("trust %v: expected %s to be left out", trust, name)
+			}
+		}
+		for _, name := range []string{"talks/raw.html", "talks/sub/image.svg"} {
+			if exists(name) != trust {
+				t.Errorf("trust %v: expected %s to be written %v", trust, name, trust)
+			}
+		}
+	}
+
+	// Pages link to the assets relative to their own directory.
+	for page, base := range map[string]string{
+		"index.html":                 "./",
+		"talks/test.slide.html":      "../",
+		"talks/sub/doc.article.html": "../../",
+		"tags/go/index.html":         "../../",
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(page)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			`href="` + base + `static/spinner.css"`,
+			`src="` + base + `static/ui.js"`,
+			`href="` + base + `feed.atom"`,
+		} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s: expected %s", page, want)
+			}
+		}
+		if strings.Contains(string(b), `href="/`) || strings.Contains(string(b), `src="/`) {
+			t.Errorf("%s: expected only relative urls", page)
+		}
+	}
+}
+
+func TestExportNotes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vectypresent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "talks")
+	if err := os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "test.slide"), []byte(testSlide), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, notes := range []bool{false, true} {
+		out := filepath.Join(dir, "out")
+		os.RemoveAll(out)
+		if err := Export(src, out, ExportOptions{Notes: notes}); err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		err := filepath.WalkDir(out, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			b, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(string(b), "a presenter note") {
+				found = append(found, filepath.ToSlash(strings.TrimPrefix(p, out)))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !notes && len(found) > 0 {
+			t.Errorf("expected no presenter notes in the site got them in %v", found)
+		}
+		if notes && len(found) == 0 {
+			t.Error("expected the presenter notes to be kept")
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"encoding/xml"
+	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+)
+
+// feedLimit is the maximum number of entries in a feed.
+const feedLimit = 50
+
+const (
+	atomNS    = "http://www.w3.org/2005/Atom"
+	sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	NS      string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Link      atomLink     `xml:"link"`
+	Published string       `xml:"published,omitempty"`
+	Updated   string       `xml:"updated"`
+	Authors   []atomPerson `xml:"author"`
+	Summary   *atomText    `xml:"summary,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// site builds the absolute urls of the pages of a site, for feeds and
+// sitemaps.
+type site struct {
+	fsys fs.FS
+
+	// base is the absolute url of the root of the site, ending with a slash.
+	base string
+
+	// static is true for sites written by Export, where documents are
+	// rendered by html files.
+	static bool
+
+	// docs caches the parsed articles by path when it is not nil.
+	docs *sync.Map
+}
+
+// parsed is a document cached in site.docs, with the error parsing it.
+type parsed struct {
+	doc *models.Doc
+	err error
+}
+
+// parse parses the article f, or returns it from docs if it was parsed
+// before.
+func (s site) parse(f *models.File) (*models.Doc, error) {
+	if s.docs != nil {
+		if v, ok := s.docs.Load(f.Path()); ok {
+			p := v.(parsed)
+			return p.doc, p.err
+		}
+	}
+	doc, err := present.ParseFS(s.fsys, f.Path(), 0)
+	if s.docs != nil {
+		s.docs.Store(f.Path(), parsed{doc, err})
+	}
+	return doc, err
+}
+
+// page returns the url of the page rendering f.
+func (s site) page(f *models.File) string {
+	u := f.URL()
+	switch {
+	case f.IsDir:
+		u += "/"
+	case s.static && (f.IsSlide() || f.IsArticle()):
+		u += ".html"
+	}
+	return s.url(u)
+}
+
+// url returns the absolute url of the site path p.
+func (s site) url(p string) string {
+	return s.base + strings.TrimPrefix(p, "/")
+}
+
+// feedPath returns the path of the feed of the articles under the directory d.
+func feedPath(d *models.File) string {
+	return "/feeds" + d.URL() + ".atom"
+}
+
+// writeFeed writes the Atom feed of the articles under d, newest first. self is
+// the path the feed is served at.
+func (s site) writeFeed(w io.Writer, d *models.File, self string) error {
+	var articles []*models.File
+	walkFiles(d, func(f *models.File) {
+		if f.IsArticle() {
+			articles = append(articles, f)
+		}
+	})
+	feed := &atomFeed{
+		NS:    atomNS,
+		Title: d.BaseName(),
+		ID:    s.url(self),
+		Links: []atomLink{
+			{Rel: "self", Href: s.url(self)},
+			{Rel: "alternate", Href: s.page(d)},
+		},
+	}
+	type dated struct {
+		entry atomEntry
+		time  time.Time
+	}
+	var entries []dated
+	var updated time.Time
+	for _, f := range articles {
+		doc, err := s.parse(f)
+		if err != nil {
+			// Broken articles are left out, the error shows when opening
+			// them.
+			continue
+		}
+		t := doc.Time
+		if t.IsZero() {
+			if info, err := fs.Stat(s.fsys, f.Path()); err == nil {
+				t = info.ModTime()
+			}
+		}
+		e := atomEntry{
+			Title:   doc.Title,
+			ID:      s.page(f),
+			Link:    atomLink{Href: s.page(f)},
+			Updated: t.UTC().Format(time.RFC3339),
+		}
+		if !doc.Time.IsZero() {
+			e.Published = e.Updated
+		}
+		for i := range doc.Authors {
+			if name := doc.Authors[i].Name(); name != "" {
+				e.Authors = append(e.Authors, atomPerson{Name: name})
+			}
+		}
+		if p := firstParagraph(doc.Sections); p != "" {
+			e.Summary = &atomText{Type: "html", Body: string(present.Style(p))}
+		}
+		if t.After(updated) {
+			updated = t
+		}
+		entries = append(entries, dated{e, t})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].time.After(entries[j].time)
+	})
+	for i := 0; i < len(entries) && i < feedLimit; i++ {
+		feed.Entries = append(feed.Entries, entries[i].entry)
+	}
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+	return writeXML(w, feed)
+}
+
+// firstParagraph returns the text of the first paragraph in sections, joined
+// in a single line.
+func firstParagraph(sections []models.Section) string {
+	for _, s := range sections {
+		for _, e := range s.Elem {
+			switch v := e.(type) {
+			case models.Text:
+				if !v.Pre && len(v.Lines) > 0 {
+					return strings.Join(v.Lines, " ")
+				}
+			case models.Section:
+				if p := firstParagraph([]models.Section{v}); p != "" {
+					return p
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// writeSitemap writes the sitemap listing every page of the tree rooted at
+// root, including the tag pages.
+func (s site) writeSitemap(w io.Writer, root *models.File) error {
+	set := &urlSet{NS: sitemapNS}
+	walkFiles(root, func(f *models.File) {
+		if !f.IsDir && !f.IsSlide() && !f.IsArticle() {
+			return
+		}
+		u := sitemapURL{Loc: s.page(f)}
+		if f.Meta != nil && !f.Meta.Time.IsZero() {
+			u.LastMod = f.Meta.Time.Format("2006-01-02")
+		}
+		set.URLs = append(set.URLs, u)
+	})
+	tags := root.Tags()
+	if len(tags) > 0 {
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.url("/tags/")})
+	}
+	for _, t := range tags {
+		if s.static && !validTagName(t) {
+			continue
+		}
+		set.URLs = append(set.URLs, sitemapURL{Loc: s.url(tagPage(t).URL() + "/")})
+	}
+	return writeXML(w, set)
+}
+
+func walkFiles(d *models.File, fn func(*models.File)) {
+	fn(d)
+	for _, c := range d.Children {
+		walkFiles(c, fn)
+	}
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// site returns the site served by h. Its base url is Options.URL, or derived
+// from the request when it is empty. Articles are parsed once, until files
+// change.
+func (h *Handler) site(r *http.Request) site {
+	if base := h.opts.URL; base != "" {
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		return site{fsys: h.fsys, base: base, docs: h.docs}
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return site{fsys: h.fsys, base: scheme + "://" + r.Host + h.prefix, docs: h.docs}
+}
+
+// serveFeed serves the feed of all the articles, on /feed.atom, and the feeds
+// of the directories, on /feeds/<dir>.atom.
+func (h *Handler) serveFeed(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Path
+	key := "/"
+	if u != "/feed.atom" {
+		key = strings.TrimPrefix(u, "/feeds")
+		if !strings.HasSuffix(key, ".atom") {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		key = strings.TrimSuffix(key, ".atom")
+	}
+	v, ok := h.cache.Load(key)
+	if !ok || !v.(*models.File).IsDir {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if err := h.site(r).writeFeed(w, v.(*models.File), u); err != nil {
+		h.opts.Logger.Println(err)
+	}
+}
+
+// serveSitemap serves the sitemap of the pages.
+func (h *Handler) serveSitemap(w http.ResponseWriter, r *http.Request) {
+	v, _ := h.cache.Load("/")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	if err := h.site(r).writeSitemap(w, v.(*models.File)); err != nil {
+		h.opts.Logger.Println(err)
+	}
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFeeds(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/first.article":  {Data: []byte("First\n2 Jan 2015\n\nAlice\n\n* Intro\n\nThe *first* post.\n")},
+		"blog/z/next.article": {Data: []byte("Next\n3 Mar 2020\n\nBob\n\n* Intro\n\n\tcode\n\nThe next post.\n")},
+		"blog/talk.slide":     {Data: []byte(testSlide)},
+	}
+	h, err := New(Options{FS: fsys, Root: "blog", BasePath: "/site/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var tests = []struct {
+		path    string
+		entries []string
+	}{
+		{"/site/feed.atom", []string{"Next", "First"}},
+		{"/site/feeds/blog.atom", []string{"Next", "First"}},
+		{"/site/feeds/blog/z.atom", []string{"Next"}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		r.Header.Set("X-Forwarded-Proto", "javascript")
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200 got %d", tt.path, w.Code)
+		}
+		feed := &atomFeed{}
+		if err := xml.Unmarshal(w.Body.Bytes(), feed); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range feed.Entries {
+			got = append(got, e.Title)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.entries, ",") {
+			t.Errorf("%s: expected entries %v got %v", tt.path, tt.entries, got)
+		}
+		if feed.ID != "http://example.com"+tt.path {
+			t.Errorf("%s: expected the feed url as id got %q", tt.path, feed.ID)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/site/feed.atom", nil))
+	feed := &atomFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), feed); err != nil {
+		t.Fatal(err)
+	}
+	e := feed.Entries[1]
+	if e.Link.Href != "http://example.com/site/blog/first.article" {
+		t.Errorf("expected an absolute link got %q", e.Link.Href)
+	}
+	if e.Summary == nil || e.Summary.Body != "The <b>first</b> post." {
+		t.Errorf("expected the first paragraph as summary got %+v", e.Summary)
+	}
+	if len(e.Authors) != 1 || e.Authors[0].Name != "Alice" {
+		t.Errorf("expected author Alice got %+v", e.Authors)
+	}
+	if feed.Entries[0].Summary.Body != "The next post." {
+		t.Errorf("expected code blocks to be skipped got %q", feed.Entries[0].Summary.Body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/site/feeds/blog/first.article.atom", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected no feed for files got status %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/site/sitemap.xml", nil))
+	set := &urlSet{}
+	if err := xml.Unmarshal(w.Body.Bytes(), set); err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, u := range set.URLs {
+		locs = append(locs, u.Loc)
+	}
+	want := []string{
+		"http://example.com/site/blog/",
+		"http://example.com/site/blog/first.article",
+		"http://example.com/site/blog/talk.slide",
+		"http://example.com/site/blog/z/",
+		"http://example.com/site/blog/z/next.article",
+	}
+	if strings.Join(locs, " ") != strings.Join(want, " ") {
+		t.Errorf("expected sitemap %v got %v", want, locs)
+	}
+
+	// Articles are parsed once, the tree isn't watched.
+	fsys["blog/first.article"].Data = []byte("Changed\n\n* Intro\n\nChanged.\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/site/feed.atom", nil))
+	if strings.Contains(w.Body.String(), "Changed") {
+		t.Error("expected the parsed article to be reused")
+	}
+}
+
+func TestFeedsURL(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/first.article": {Data: []byte("First\n2 Jan 2015\n\nAlice\n\n* Intro\n\nThe first post.\n")},
+	}
+	h, err := New(Options{FS: fsys, Root: "blog", BasePath: "/site/", URL: "https://example.org/talks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://evil.example/site/feed.atom", nil)
+	r.Header.Set("X-Forwarded-Proto", "ftp")
+	h.ServeHTTP(w, r)
+	feed := &atomFeed{}
+	if err := xml.Unmarshal(w.Body.Bytes(), feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed.ID != "https://example.org/talks/feed.atom" {
+		t.Errorf("expected the feed url from the options got %q", feed.ID)
+	}
+	if len(feed.Entries) != 1 || feed.Entries[0].Link.Href != "https://example.org/talks/blog/first.article" {
+		t.Errorf("expected links from the options got %+v", feed.Entries)
+	}
+}
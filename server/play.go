@@ -0,0 +1,239 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// maxProgram is the maximum size of a program sent to the runner.
+	maxProgram = 1 << 20
+
+	// runTimeout is how long a program is allowed to run by default.
+	runTimeout = 10 * time.Second
+
+	// buildTimeout is how long building a program may take, the first build
+	// also compiles the packages it imports.
+	buildTimeout = time.Minute
+
+	// maxOutput is the maximum number of bytes a program may write by
+	// default, it is killed when writing more.
+	maxOutput = 100 << 10
+)
+
+// PlayEvent is streamed to the client while a program runs.
+type PlayEvent struct {
+	// Kind is "stdout" or "stderr" for output of the program, "build" for
+	// compile errors and "end" for the last event, its body is empty if the
+	// program exited successfully and the reason it didn't otherwise.
+	Kind string `json:"kind"`
+	Body string `json:"body"`
+}
+
+// Runner compiles and runs playground snippets with the local Go toolchain.
+//
+// Programs are posted to /compile, which responds with newline separated json
+// encoded PlayEvent values as the program runs. The program is killed when the
+// request is canceled. /fmt formats the posted source code. Requests must come
+// from pages of the same origin and set the X-Requested-With header, so other
+// sites can't run code on the machine of the presenter.
+type Runner struct {
+	// Go is the go command, it defaults to the one found in PATH.
+	Go string
+
+	// Timeout is how long a program is allowed to run.
+	Timeout time.Duration
+
+	// MaxOutput is the maximum number of bytes a program may write to
+	// stdout and stderr together.
+	MaxOutput int
+}
+
+// NewRunner returns a Runner with the default limits.
+func NewRunner() *Runner {
+	return &Runner{
+		Go:        "go",
+		Timeout:   runTimeout,
+		MaxOutput: maxOutput,
+	}
+}
+
+func (p *Runner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxProgram))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	switch r.URL.Path {
+	case "/compile":
+		p.compile(w, r, src)
+	case "/fmt":
+		p.format(w, src)
+	default:
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}
+}
+
+// compile builds and runs the program src, streaming its output.
+func (p *Runner) compile(w http.ResponseWriter, r *http.Request, src []byte) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	s := &playStream{enc: json.NewEncoder(w), flusher: flusher}
+	err := p.run(r.Context(), src, s.send)
+	end := &PlayEvent{Kind: "end"}
+	if err != nil {
+		end.Body = err.Error()
+	}
+	s.send(end)
+}
+
+// run builds the program src in a temporary directory and runs it, passing
+// its output to send. It returns an error if the program could not be built or
+// didn't exit successfully.
+func (p *Runner) run(ctx context.Context, src []byte, send func(*PlayEvent)) error {
+	dir, err := os.MkdirTemp("", "vectypresent-play-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "prog.go"), src, 0600); err != nil {
+		return err
+	}
+	bin := filepath.Join(dir, "prog")
+	bctx, cancel := context.WithTimeout(ctx, buildTimeout)
+	defer cancel()
+	build := exec.CommandContext(bctx, p.Go, "build", "-o", bin, "prog.go")
+	build.Dir = dir
+	if out, err := build.CombinedOutput(); err != nil {
+		if bctx.Err() == context.DeadlineExceeded {
+			return errors.New("build timed out")
+		}
+		if len(out) == 0 {
+			// The toolchain itself failed, like when go is not installed.
+			return fmt.Errorf("build failed: %v", err)
+		}
+		send(&PlayEvent{Kind: "build", Body: string(out)})
+		return errors.New("build failed")
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	limit := &outputLimit{max: p.MaxOutput, kill: cancel}
+	cmd := exec.CommandContext(rctx, bin)
+	cmd.Dir = dir
+	cmd.Stdout = &playWriter{kind: "stdout", send: send, limit: limit}
+	cmd.Stderr = &playWriter{kind: "stderr", send: send, limit: limit}
+	// Processes started by the program may keep its outputs open after it
+	// is killed.
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	switch {
+	case limit.exceeded():
+		return fmt.Errorf("output exceeded %d bytes", p.MaxOutput)
+	case ctx.Err() != nil:
+		return errors.New("killed")
+	case rctx.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("timed out after %v", p.Timeout)
+	}
+	return err
+}
+
+// format responds with src formatted by gofmt. Snippets don't need to be
+// complete files, lists of declarations or statements are formatted too.
+func (p *Runner) format(w http.ResponseWriter, src []byte) {
+	var res struct {
+		Body  string `json:"body,omitempty"`
+		Error string `json:"error,omitempty"`
+	}
+	out, err := format.Source(src)
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Body = string(out)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	WriteJson(w, &res)
+}
+
+// playStream writes events to a response, flushing after each one.
+type playStream struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	flusher http.Flusher
+}
+
+func (s *playStream) send(e *PlayEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.enc.Encode(e); err == nil {
+		s.flusher.Flush()
+	}
+}
+
+// outputLimit counts the output of a program, which is killed once it writes
+// more than max bytes.
+type outputLimit struct {
+	mu   sync.Mutex
+	max  int
+	n    int
+	over bool
+	kill func()
+}
+
+// take returns how many of n bytes may still be written.
+func (l *outputLimit) take(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.n+n > l.max {
+		n = l.max - l.n
+		l.over = true
+		l.kill()
+	}
+	l.n += n
+	return n
+}
+
+func (l *outputLimit) exceeded() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.over
+}
+
+// playWriter sends what a program writes to one of its outputs as events.
+type playWriter struct {
+	kind  string
+	send  func(*PlayEvent)
+	limit *outputLimit
+}
+
+func (w *playWriter) Write(b []byte) (int, error) {
+	if n := w.limit.take(len(b)); n > 0 {
+		w.send(&PlayEvent{Kind: w.kind, Body: string(b[:n])})
+	}
+	return len(b), nil
+}
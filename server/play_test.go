@@ -0,0 +1,130 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not found")
+	}
+	p := NewRunner()
+	p.Timeout = 2 * time.Second
+	p.MaxOutput = 64
+	var tests = []struct {
+		name   string
+		src    string
+		events []PlayEvent
+	}{
+		{"hello", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hello\") }\n",
+			[]PlayEvent{{"stdout", "hello\n"}, {"end", ""}}},
+		// stdout and stderr are read concurrently, so their events can't be
+		// ordered.
+		{"exit", `package main
+
+import ("fmt"; "os")
+
+func main() {
+	fmt.Fprintln(os.Stderr, "oops")
+	os.Exit(3)
+}
+`, []PlayEvent{{"stderr", "oops\n"}, {"end", "exit status 3"}}},
+		{"build", "package main\n\nfunc main() { undefined() }\n",
+			[]PlayEvent{{"build", "undefined: undefined"}, {"end", "build failed"}}},
+		{"output", `package main
+
+import "fmt"
+
+func main() {
+	for {
+		fmt.Print("0123456789")
+	}
+}
+`, []PlayEvent{{"stdout", "0123456789"}, {"end", "output exceeded 64 bytes"}}},
+		{"timeout", "package main\n\nimport \"time\"\n\nfunc main() { time.Sleep(time.Hour) }\n",
+			[]PlayEvent{{"end", "timed out after 2s"}}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, postRequest("/compile", tt.src))
+		var events []PlayEvent
+		dec := json.NewDecoder(w.Body)
+		for dec.More() {
+			var e PlayEvent
+			if err := dec.Decode(&e); err != nil {
+				t.Fatal(err)
+			}
+			if n := len(events); n > 0 && events[n-1].Kind == e.Kind && e.Kind != "end" {
+				// Output may arrive in several writes.
+				events[n-1].Body += e.Body
+				continue
+			}
+			events = append(events, e)
+		}
+		if len(events) != len(tt.events) {
+			t.Errorf("%s: expected events %v got %v", tt.name, tt.events, events)
+			continue
+		}
+		for i, e := range events {
+			want := tt.events[i]
+			if e.Kind != want.Kind || !strings.Contains(e.Body, want.Body) ||
+				(want.Body == "" && e.Body != "") {
+				t.Errorf("%s: expected event %v got %v", tt.name, want, e)
+			}
+		}
+	}
+}
+
+func TestRunnerFormat(t *testing.T) {
+	var tests = []struct {
+		src, body, err string
+	}{
+		{"func f(){\nreturn}\n", "func f() {\n\treturn\n}\n", ""},
+		{"x:=1\nprintln( x )", "x := 1\nprintln(x)", ""},
+		{"func (", "", "expected"},
+	}
+	p := NewRunner()
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, postRequest("/fmt", tt.src))
+		var res struct{ Body, Error string }
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Body != tt.body || !strings.Contains(res.Error, tt.err) || (tt.err == "" && res.Error != "") {
+			t.Errorf("%q: expected %q, %q got %q, %q", tt.src, tt.body, tt.err, res.Body, res.Error)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/compile", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status 405 for GET got %d", w.Code)
+	}
+}
+
+func TestRunnerOrigin(t *testing.T) {
+	p := NewRunner()
+	cross := postRequest("/fmt", "x:=1")
+	cross.Header.Set("Origin", "https://evil.example")
+	simple := postRequest("/compile", "package main")
+	simple.Header.Del("X-Requested-With")
+	for _, r := range []*http.Request{cross, simple} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s from %q: expected status 403 got %d", r.URL.Path, r.Header.Get("Origin"), w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, postRequest("/fmt", "x:=1"))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200 for the same origin got %d", w.Code)
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// codeAlphabet is used for session codes, it leaves out letters and digits
+// that are easily confused when typed on a phone.
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const (
+	codeLength = 5
+
+	// sessionGrace is how long a session without devices is kept, so a
+	// presenter reloading the page doesn't lose it.
+	sessionGrace = 10 * time.Minute
+)
+
+// Message is exchanged with the devices taking part in a remote session.
+type Message struct {
+	// Type is "state" for the message sent when joining and "slide" for slide
+	// changes.
+	Type  string `json:"type"`
+	Deck  string `json:"deck,omitempty"`
+	Slide int    `json:"slide"`
+}
+
+// Hub keeps the remote control sessions, which share the active slide of a
+// deck between devices over WebSocket.
+//
+// Sessions are created with POST /remote/session?deck=<url>, devices join them
+// with /remote/ws?code=<code> and /remote/<code> redirects to the deck.
+type Hub struct {
+	prefix string
+
+	// valid returns true if deck is the url of a slide deck.
+	valid func(deck string) bool
+
+	Logger *log.Logger
+
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+type session struct {
+	code    string
+	deck    string
+	slide   int
+	devices map[*device]struct{}
+	expire  *time.Timer
+}
+
+type device struct {
+	conn *websocket.Conn
+
+	// send holds the message waiting to be written, only the latest slide
+	// matters so it replaces one that wasn't written yet.
+	send chan *Message
+}
+
+// NewHub returns a Hub for the decks accepted by valid. prefix is the path the
+// handler is mounted at, it is used to redirect to decks.
+func NewHub(prefix string, valid func(deck string) bool) *Hub {
+	return &Hub{
+		prefix:   prefix,
+		valid:    valid,
+		Logger:   log.New(os.Stderr, "", log.LstdFlags),
+		sessions: make(map[string]*session),
+	}
+}
+
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch p := strings.TrimPrefix(r.URL.Path, "/remote/"); p {
+	case "session":
+		h.create(w, r)
+	case "ws":
+		h.join(w, r)
+	default:
+		h.redirect(w, r, strings.ToUpper(p))
+	}
+}
+
+// create starts a session for the deck named in the query.
+func (h *Hub) create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	deck := r.URL.Query().Get("deck")
+	if !h.valid(deck) {
+		http.Error(w, "unknown deck", http.StatusBadRequest)
+		return
+	}
+	h.mu.Lock()
+	var code string
+	for code == "" || h.sessions[code] != nil {
+		code = newCode()
+	}
+	s := &session{
+		code:    code,
+		deck:    deck,
+		devices: make(map[*device]struct{}),
+	}
+	s.expire = time.AfterFunc(sessionGrace, func() { h.expire(s) })
+	h.sessions[code] = s
+	h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	WriteJson(w, map[string]string{"code": code})
+}
+
+// redirect sends the browser to the deck of the session with code, joining
+// the session.
+func (h *Hub) redirect(w http.ResponseWriter, r *http.Request, code string) {
+	h.mu.Lock()
+	s := h.sessions[code]
+	h.mu.Unlock()
+	if s == nil {
+		http.NotFound(w, r)
+		return
+	}
+	u := strings.TrimSuffix(h.prefix, "/") + s.deck + "?session=" + code
+	http.Redirect(w, r, u, http.StatusFound)
+}
+
+// join upgrades the connection and adds the device to the session. Slide
+// changes sent by the device are forwarded to the other devices.
+func (h *Hub) join(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(r.URL.Query().Get("code"))
+	h.mu.Lock()
+	s := h.sessions[code]
+	h.mu.Unlock()
+	if s == nil {
+		http.NotFound(w, r)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied to the client.
+		return
+	}
+	d := &device{conn: conn, send: make(chan *Message, 1)}
+	h.mu.Lock()
+	if h.sessions[code] != s {
+		// The session expired in the meantime.
+		h.mu.Unlock()
+		conn.Close()
+		return
+	}
+	s.expire.Stop()
+	s.devices[d] = struct{}{}
+	d.deliver(&Message{Type: "state", Deck: s.deck, Slide: s.slide})
+	h.mu.Unlock()
+
+	go d.write()
+	defer h.leave(s, d)
+	for {
+		m := &Message{}
+		if err := conn.ReadJSON(m); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				h.Logger.Println(err)
+			}
+			return
+		}
+		if m.Type == "slide" {
+			h.broadcast(s, d, m.Slide)
+		}
+	}
+}
+
+// broadcast records slide as the active slide of s and sends it to every
+// device but from.
+func (h *Hub) broadcast(s *session, from *device, slide int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if slide < 0 || slide == s.slide {
+		return
+	}
+	s.slide = slide
+	m := &Message{Type: "slide", Slide: slide}
+	for d := range s.devices {
+		if d == from {
+			continue
+		}
+		d.deliver(m)
+	}
+}
+
+func (h *Hub) leave(s *session, d *device) {
+	h.mu.Lock()
+	if _, ok := s.devices[d]; ok {
+		delete(s.devices, d)
+		close(d.send)
+	}
+	if len(s.devices) == 0 {
+		s.expire.Reset(sessionGrace)
+	}
+	h.mu.Unlock()
+	d.conn.Close()
+}
+
+func (h *Hub) expire(s *session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(s.devices) == 0 && h.sessions[s.code] == s {
+		delete(h.sessions, s.code)
+	}
+}
+
+// Close ends every session and disconnects the devices.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for code, s := range h.sessions {
+		s.expire.Stop()
+		for d := range s.devices {
+			d.conn.Close()
+		}
+		delete(h.sessions, code)
+	}
+}
+
+// deliver queues m to be written to the device, replacing the message that is
+// still waiting if the device lags behind. It must be called with the lock of
+// the hub held, which makes it the only sender.
+func (d *device) deliver(m *Message) {
+	select {
+	case d.send <- m:
+		return
+	default:
+	}
+	select {
+	case <-d.send:
+	default:
+		// The message was written in the meantime.
+	}
+	d.send <- m
+}
+
+func (d *device) write() {
+	for m := range d.send {
+		b, err := json.Marshal(m)
+		if err != nil {
+			continue
+		}
+		d.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if err := d.conn.WriteMessage(websocket.TextMessage, b); err != nil {
+			d.conn.Close()
+			return
+		}
+	}
+}
+
+// newCode returns a random session code.
+func newCode() string {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRemote(t *testing.T) {
+	h := newTestHandler(t, Options{Remote: true})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	res, err := http.Post(ts.URL+"/remote/session?deck=/talks/missing.slide", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown deck got %d", http.StatusBadRequest, res.StatusCode)
+	}
+
+	res, err = http.Post(ts.URL+"/remote/session?deck=/talks/test.slide", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var created struct{ Code string }
+	err = json.NewDecoder(res.Body).Decode(&created)
+	res.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created.Code) != codeLength {
+		t.Fatalf("expected a %d letter code got %q", codeLength, created.Code)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err = client.Get(ts.URL + "/remote/" + strings.ToLower(created.Code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if want := "/talks/test.slide?session=" + created.Code; res.Header.Get("Location") != want {
+		t.Errorf("expected redirect to %q got %q", want, res.Header.Get("Location"))
+	}
+
+	ws := "ws" + strings.TrimPrefix(ts.URL, "http") + "/remote/ws?code=" + created.Code
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(ws, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		m := &Message{}
+		if err := conn.ReadJSON(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type != "state" || m.Deck != "/talks/test.slide" {
+			t.Fatalf("expected the session state got %+v", m)
+		}
+		return conn
+	}
+	presenter, viewer := dial(), dial()
+	if err := presenter.WriteJSON(&Message{Type: "slide", Slide: 3}); err != nil {
+		t.Fatal(err)
+	}
+	m := &Message{}
+	if err := viewer.ReadJSON(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Type != "slide" || m.Slide != 3 {
+		t.Errorf("expected the viewer to move to slide 3 got %+v", m)
+	}
+
+	// Devices joining later start on the active slide.
+	conn, _, err := websocket.DefaultDialer.Dial(ws, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.ReadJSON(m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Slide != 3 {
+		t.Errorf("expected a late device to start on slide 3 got %+v", m)
+	}
+}
+
+func TestDeviceDeliver(t *testing.T) {
+	d := &device{send: make(chan *Message, 1)}
+	for i := 1; i <= 3; i++ {
+		d.deliver(&Message{Type: "slide", Slide: i})
+	}
+	if m := <-d.send; m.Slide != 3 {
+		t.Errorf("expected the latest slide 3 got %d", m.Slide)
+	}
+	select {
+	case m := <-d.send:
+		t.Errorf("expected a single message got another for slide %d", m.Slide)
+	default:
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+)
+
+const (
+	// searchLimit is the maximum number of hits returned by a search.
+	searchLimit = 50
+
+	// titleWeight is how much more a match in a title counts than a match
+	// in the text.
+	titleWeight = 5
+
+	// snippetContext is the number of characters shown around a match.
+	snippetContext = 60
+)
+
+// Index is a full text index over the documents in a file system. Every
+// section is indexed separately, so hits point to the matching slide or
+// article section. The index is built on the first search after it is
+// invalidated.
+type Index struct {
+	fsys  fs.FS
+	notes bool
+
+	// Logger is used to log documents that can't be parsed.
+	Logger *log.Logger
+
+	mu      sync.Mutex
+	entries []indexEntry
+	built   bool
+}
+
+type indexEntry struct {
+	file         string
+	title        string
+	section      []int
+	sectionTitle string
+
+	// text holds the searchable text, lower holds the lower cased title and
+	// text used for matching.
+	text       string
+	lowerTitle string
+	lowerText  string
+}
+
+// NewIndex returns an index over the documents in fsys. Presenter notes are
+// only indexed if notes is true.
+func NewIndex(fsys fs.FS, notes bool) *Index {
+	return &Index{
+		fsys:   fsys,
+		notes:  notes,
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Invalidate discards the index, it is built again on the next search.
+func (x *Index) Invalidate() {
+	x.mu.Lock()
+	x.built = false
+	x.entries = nil
+	x.mu.Unlock()
+}
+
+// Search returns the hits for query in the documents of the tree root, best
+// matches first. All the words of the query must match.
+func (x *Index) Search(root *models.File, query string) []models.SearchHit {
+	terms := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+	hits := []models.SearchHit{}
+	if len(terms) == 0 {
+		return hits
+	}
+	x.mu.Lock()
+	if !x.built {
+		x.entries = nil
+		x.build(root)
+		x.built = true
+	}
+	entries := x.entries
+	x.mu.Unlock()
+
+	for _, e := range entries {
+		score := 0
+		for _, t := range terms {
+			n := titleWeight*strings.Count(e.lowerTitle, t) + strings.Count(e.lowerText, t)
+			if n == 0 {
+				score = 0
+				break
+			}
+			score += n
+		}
+		if score == 0 {
+			continue
+		}
+		hits = append(hits, models.SearchHit{
+			File:         e.file,
+			Title:        e.title,
+			Section:      e.section,
+			SectionTitle: e.sectionTitle,
+			Snippet:      snippet(e.text, e.lowerText, terms[0]),
+			Score:        score,
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	if len(hits) > searchLimit {
+		hits = hits[:searchLimit]
+	}
+	return hits
+}
+
+// build indexes the documents under d.
+func (x *Index) build(d *models.File) {
+	if d.IsDir {
+		for _, c := range d.Children {
+			x.build(c)
+		}
+		return
+	}
+	if !d.IsSlide() && !d.IsArticle() {
+		return
+	}
+	doc, err := present.ParseFS(x.fsys, d.Path(), 0)
+	if err != nil {
+		x.Logger.Println(err)
+		return
+	}
+	header := []string{doc.Subtitle, strings.Join(doc.Tags, " ")}
+	if x.notes {
+		header = append(header, doc.TitleNotes...)
+	}
+	x.add(indexEntry{
+		file:  d.URL(),
+		title: doc.Title,
+		text:  strings.Join(header, "\n"),
+	}, doc.Title)
+	for _, s := range doc.Sections {
+		x.addSection(d.URL(), doc.Title, s)
+	}
+}
+
+func (x *Index) addSection(file, title string, s models.Section) {
+	var text []string
+	for _, e := range s.Elem {
+		switch v := e.(type) {
+		case models.Section:
+			x.addSection(file, title, v)
+		case models.Text:
+			text = append(text, v.Lines...)
+		case models.List:
+			text = append(text, v.Bullet...)
+		case models.Code:
+			text = append(text, string(v.Raw))
+		case models.Caption:
+			text = append(text, v.Text)
+		case models.Link:
+			text = append(text, v.Label)
+		}
+	}
+	if x.notes {
+		text = append(text, s.Notes...)
+	}
+	x.add(indexEntry{
+		file:         file,
+		title:        title,
+		section:      s.Number,
+		sectionTitle: s.Title,
+		text:         strings.Join(text, "\n"),
+	}, s.Title)
+}
+
+// add adds e to the index, matchTitle is the title words count extra for.
+func (x *Index) add(e indexEntry, matchTitle string) {
+	e.lowerTitle = strings.ToLower(matchTitle)
+	e.lowerText = strings.ToLower(e.text)
+	x.entries = append(x.entries, e)
+}
+
+// snippet returns the text around the first occurrence of term in text, lower
+// is the lower cased text. The beginning of the text is used if the term only
+// matched the title.
+func snippet(text, lower, term string) string {
+	i := strings.Index(lower, term)
+	if i < 0 || len(lower) != len(text) {
+		// Lower casing changed the offsets, don't risk cutting runes.
+		i = 0
+	}
+	start, end := i-snippetContext, i+len(term)+snippetContext
+	if start < 0 {
+		start = 0
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+	for start > 0 && !isRuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !isRuneStart(text[end]) {
+		end++
+	}
+	s := strings.Join(strings.Fields(text[start:end]), " ")
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(text) {
+		s += "…"
+	}
+	return s
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// serveSearch responds with the hits for the q query parameter.
+func (h *Handler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	v, _ := h.cache.Load("/")
+	w.Header().Set("Content-Type", "application/json")
+	WriteJson(w, &models.SearchResult{
+		Query: q,
+		Hits:  h.index.Search(v.(*models.File), q),
+	})
+}
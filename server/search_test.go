@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+func TestSearch(t *testing.T) {
+	fsys := fstest.MapFS{
+		"talks/test.slide": {Data: []byte(testSlide)},
+		"talks/gophers.article": {Data: []byte(`Gophers
+
+* Burrows
+
+Gophers dig burrows.
+
+* Food
+
+- roots
+- bulbs
+
+: gophers also eat presenter notes
+`)},
+	}
+	var tests = []struct {
+		notes bool
+		query string
+		hits  []string
+	}{
+		{false, "burrows", []string{"/talks/gophers.article#1"}},
+		{false, "gophers", []string{"/talks/gophers.article#", "/talks/gophers.article#1"}},
+		{false, "roots bulbs", []string{"/talks/gophers.article#2"}},
+		{false, "some text", []string{"/talks/test.slide#1"}},
+		{false, "presenter", nil},
+		{true, "presenter", []string{"/talks/gophers.article#2", "/talks/test.slide#1"}},
+		{false, "", nil},
+	}
+	for _, tt := range tests {
+		h, err := New(Options{FS: fsys, Root: "talks", Notes: tt.notes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/search?q="+url.QueryEscape(tt.query), nil))
+		h.Close()
+		res := &models.SearchResult{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, hit := range res.Hits {
+			n := ""
+			if len(hit.Section) > 0 {
+				n = models.Section{Number: hit.Section}.FormattedNumber()
+				n = n[:len(n)-1]
+			}
+			got = append(got, hit.File+"#"+n)
+		}
+		if len(got) != len(tt.hits) {
+			t.Errorf("%q: expected %v got %v", tt.query, tt.hits, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.hits[i] {
+				t.Errorf("%q: expected %v got %v", tt.query, tt.hits, got)
+				break
+			}
+		}
+	}
+}
@@ -1,40 +1,109 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	gzip "github.com/NYTimes/gziphandler"
 	"github.com/elazarl/go-bindata-assetfs"
 	"github.com/gernest/vectypresent/data"
 	"github.com/gernest/vectypresent/present"
 	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/render"
 	"github.com/urfave/cli"
 )
 
 func Command() cli.Command {
 	return cli.Command{
 		Name: "serve",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "http",
+				Usage: "address to listen on",
+				Value: ":8080",
+			},
+			cli.StringFlag{
+				Name:  "base-path",
+				Usage: "path prefix the app is served under, for use behind a reverse proxy",
+				Value: "/",
+			},
+			cli.StringFlag{
+				Name:  "url",
+				Usage: "absolute url the app is reached at, used by the feeds and the sitemap",
+			},
+			cli.StringFlag{
+				Name:  "tls-cert",
+				Usage: "path to the TLS certificate file",
+			},
+			cli.StringFlag{
+				Name:  "tls-key",
+				Usage: "path to the TLS private key file",
+			},
+			cli.BoolFlag{
+				Name:  "notes",
+				Usage: "send presenter notes to the browser",
+			},
+			cli.BoolFlag{
+				Name:  "play",
+				Usage: "run playground snippets with the local Go toolchain, anyone reaching the server can run code",
+			},
+			cli.BoolFlag{
+				Name:  "trust-html",
+				Usage: "use .html includes and urls as written instead of sanitizing them",
+			},
+			cli.BoolFlag{
+				Name:  "watch",
+				Usage: "reload the directory tree and open pages when files change",
+			},
+			cli.BoolFlag{
+				Name:  "remote",
+				Usage: "enable remote control sessions shared by several devices",
+			},
+			cli.BoolFlag{
+				Name:  "record",
+				Usage: "save rehearsal recordings posted by the browser next to the decks",
+			},
+		},
 		Action: func(ctx *cli.Context) error {
-			return Server(ctx.Args().First())
+			return Server(Config{
+				Options: Options{
+					Root:      ctx.Args().First(),
+					BasePath:  ctx.String("base-path"),
+					URL:       ctx.String("url"),
+					Notes:     ctx.Bool("notes"),
+					Play:      ctx.Bool("play"),
+					TrustHTML: ctx.Bool("trust-html"),
+					Watch:     ctx.Bool("watch"),
+					Remote:    ctx.Bool("remote"),
+					Record:    ctx.Bool("record"),
+				},
+				Addr:    ctx.String("http"),
+				TLSCert: ctx.String("tls-cert"),
+				TLSKey:  ctx.String("tls-key"),
+			})
 		},
 	}
 }
 
 const (
-	dirSheet     = "/static/dir.css"
-	articleSheet = "/static/article.css"
-	slideSheet   = "/static/styles.css"
+	dirSheet     = "static/dir.css"
+	articleSheet = "static/article.css"
+	slideSheet   = "static/styles.css"
 )
 
 const indexTpl = `
@@ -46,138 +115,598 @@ const indexTpl = `
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
     <meta http-equiv="X-UA-Compatible" content="ie=edge">
     <title>{{.doc.BaseName}}</title>
-    <link type="text/css" rel="stylesheet" href="/static/spinner.css">
-    <link type="text/css" rel="stylesheet" href="{{.sheet}}">
+    <link type="text/css" rel="stylesheet" href="{{.base}}static/spinner.css">
+    <link type="text/css" rel="stylesheet" href="{{.base}}{{.sheet}}">
+    <link rel="alternate" type="application/atom+xml" href="{{.base}}feed.atom">
     <script>
         window.localStorage.setItem("ACTIVE_ROUTE", "{{.doc.URL}}")
+        window.VECTYPRESENT = {base: "{{.base}}", static: {{.static}}}
     </script>
 </head>
 
 <body>
+{{- if .body}}
+{{.body}}
+<script type="application/json" id="vectypresent-doc" data-route="{{.doc.URL}}">{{.data}}</script>
+{{- else}}
 	<div class="loading">loading...</div>
+{{- end}}
 </body>
 <footer>
-    <script src="/static/ui.js"></script>
+    <script src="{{.base}}static/ui.js"></script>
 </footer>
 
 </html>
 `
 
-func Server(path string) error {
-	if path == "" {
-		return errors.New("no directory specified, please supply the path to directory to render")
+// watchInterval is how often the directory is polled for changes in watch
+// mode.
+const watchInterval = time.Second
+
+// Options configures the handler returned by New.
+type Options struct {
+	// Root is the directory with the documents to serve. When FS is set it
+	// names a directory in FS.
+	Root string
+
+	// FS is the file system the documents are served from. The operating
+	// system's file system is used if FS is nil.
+	FS fs.FS
+
+	// BasePath is the path prefix all urls are served under. This allows
+	// mounting the app behind a reverse proxy at a sub path like /talks/.
+	BasePath string
+
+	// URL is the absolute url the app is reached at, including BasePath,
+	// like https://example.com/talks/. Feeds and the sitemap link to it. When
+	// it is empty their links are built from the host and scheme of the
+	// request, headers set by proxies are ignored.
+	URL string
+
+	// Mode is the mode used to parse documents.
+	Mode present.ParseMode
+
+	// Notes enables sending presenter notes to the browser, they are stripped
+	// from documents otherwise.
+	Notes bool
+
+	// Play enables runnable playground snippets, which are compiled and run
+	// by the local Go toolchain. It lets anyone reaching the server run code
+	// on it.
+	Play bool
+
+	// TrustHTML disables the sanitization of the documents under Root, see
+	// present.Context.TrustHTML. Leave it off when serving documents written
+	// by others.
+	TrustHTML bool
+
+	// Watch enables reloading the tree and open pages when files change.
+	Watch bool
+
+	// Remote enables remote control sessions, which let devices on the
+	// network share the active slide of a deck.
+	Remote bool
+
+	// Record enables saving timing recordings posted by the browser to the
+	// .timing file of the deck. Saved recordings are served either way.
+	Record bool
+
+	// Logger is used to log errors, defaults to the standard logger.
+	Logger *log.Logger
+}
+
+// Handler serves the directory tree, documents and the vecty frontend.
+type Handler struct {
+	opts    Options
+	prefix  string
+	fsys    fs.FS
+	mux     *http.ServeMux
+	handler http.Handler
+	cache   *sync.Map
+	docs    *sync.Map
+	t       *template.Template
+	remote  *Hub
+	index   *Index
+	done    chan struct{}
+
+	// dir is the directory fsys was opened at, it is empty if the documents
+	// are served from Options.FS. Recordings are only saved to dir.
+	dir      string
+	timingMu sync.Mutex
+}
+
+// New returns a Handler serving the documents in opts.Root. Call Close to
+// release the resources used by the handler.
+func New(opts Options) (*Handler, error) {
+	if opts.Root == "" {
+		return nil, errors.New("no directory specified, please supply the path to directory to render")
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	fsys, root, dir := opts.FS, opts.Root, ""
+	if fsys == nil {
+		// Names in the tree are relative to the parent of the served directory.
+		root = filepath.Clean(root)
+		dir = filepath.Dir(root)
+		fsys = os.DirFS(dir)
+		root = filepath.Base(root)
+	}
+	if !fs.ValidPath(root) || root == "." {
+		return nil, fmt.Errorf("%q is not a valid directory name", root)
 	}
-	mux := http.NewServeMux()
-	cache := &sync.Map{}
 	t, err := template.New("index.html").Parse(indexTpl)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	dirDoc, err := Load(path)
+	dirDoc, err := LoadFS(fsys, root)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	basePath := fmt.Sprintf("/%s/", filepath.Base(path))
-	cache.Store("/", dirDoc)
-	dirDoc.Cache(cache)
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		t.ExecuteTemplate(w, "index.html", map[string]interface{}{
-			"doc":   dirDoc,
-			"sheet": dirSheet,
-		})
+	h := &Handler{
+		opts:   opts,
+		prefix: cleanBasePath(opts.BasePath),
+		fsys:   fsys,
+		mux:    http.NewServeMux(),
+		cache:  &sync.Map{},
+		docs:   &sync.Map{},
+		t:      t,
+		done:   make(chan struct{}),
+		dir:    dir,
+		index:  NewIndex(fsys, opts.Notes),
+	}
+	h.index.Logger = opts.Logger
+	Refresh(h.cache, dirDoc)
+	h.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		doc, _ := h.cache.Load("/")
+		h.render(w, doc, dirSheet)
 	})
-	mux.HandleFunc("/context", func(w http.ResponseWriter, r *http.Request) {
-		WriteJson(w, dirDoc)
+	h.mux.HandleFunc("/context", func(w http.ResponseWriter, r *http.Request) {
+		doc, _ := h.cache.Load("/")
+		WriteJson(w, doc)
 	})
-	mux.Handle("/static/", http.StripPrefix(
+	if opts.Watch {
+		watcher := NewWatcher(fsys, root, watchInterval)
+		watcher.Logger = opts.Logger
+		watcher.OnChange = func(e *Event) error {
+			h.index.Invalidate()
+			h.docs.Range(func(k, _ interface{}) bool {
+				h.docs.Delete(k)
+				return true
+			})
+			e.Meta = hasDocument(e.Paths)
+			if !e.Tree && !e.Meta {
+				return nil
+			}
+			doc, err := LoadFS(fsys, root)
+			if err != nil {
+				return err
+			}
+			Refresh(h.cache, doc)
+			return nil
+		}
+		go func() {
+			if err := watcher.Watch(h.done); err != nil {
+				opts.Logger.Println(err)
+			}
+		}()
+		h.mux.Handle("/events", watcher)
+	}
+	if opts.Remote {
+		h.remote = NewHub(h.prefix, func(deck string) bool {
+			v, ok := h.cache.Load(deck)
+			return ok && v.(*models.File).IsSlide()
+		})
+		h.remote.Logger = opts.Logger
+		h.mux.Handle("/remote/", h.remote)
+	}
+	if opts.Play {
+		runner := NewRunner()
+		h.mux.Handle("/compile", runner)
+		h.mux.Handle("/fmt", runner)
+	}
+	h.mux.Handle("/static/", http.StripPrefix(
 		"/static/", gzip.GzipHandler(http.FileServer(&assetfs.AssetFS{
 			Asset:     data.Asset,
 			AssetDir:  data.AssetDir,
 			AssetInfo: data.AssetInfo,
 		})),
 	))
-	fileServer := http.FileServer(http.Dir(path))
-	mux.Handle(basePath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := r.URL.Path
-		if strings.HasSuffix(u, "/") {
-			// It is a directory listing.
-			dir := strings.TrimSuffix(u, "/")
-			if doc, ok := cache.Load(dir); ok {
-				t.ExecuteTemplate(w, "index.html", map[string]interface{}{
-					"doc":   doc,
-					"sheet": dirSheet,
-				})
-				return
-			}
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	h.mux.Handle(dirDoc.URL()+"/", http.HandlerFunc(h.servePage))
+	h.mux.Handle("/files/", http.StripPrefix("/files", http.HandlerFunc(h.serveFile)))
+	h.mux.HandleFunc("/search", h.serveSearch)
+	h.mux.HandleFunc("/tags/", h.serveTags)
+	h.mux.HandleFunc("/feed.atom", h.serveFeed)
+	h.mux.HandleFunc("/feeds/", h.serveFeed)
+	h.mux.HandleFunc("/sitemap.xml", h.serveSitemap)
+	h.mux.Handle("/timings/", http.StripPrefix("/timings", http.HandlerFunc(h.serveTimings)))
+	h.handler = h.mux
+	if h.prefix != "/" {
+		h.handler = mountAt(h.prefix, http.StripPrefix(strings.TrimSuffix(h.prefix, "/"), h.mux))
+	}
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+// Close stops watching for changes and ends open event streams and remote
+// sessions.
+func (h *Handler) Close() error {
+	select {
+	case <-h.done:
+	default:
+		close(h.done)
+		if h.remote != nil {
+			h.remote.Close()
+		}
+	}
+	return nil
+}
+
+func (h *Handler) render(w http.ResponseWriter, doc interface{}, sheet string) {
+	err := h.t.ExecuteTemplate(w, "index.html", index(doc, sheet, h.prefix))
+	if err != nil {
+		h.opts.Logger.Println(err)
+	}
+}
+
+// renderDoc renders the page of the slide or article d with the document in
+// its body, so it shows before the frontend is loaded and without JavaScript.
+// Documents that can't be parsed get an empty page, the frontend shows the
+// error.
+func (h *Handler) renderDoc(w http.ResponseWriter, d *models.File, sheet string, active int) {
+	data := index(d, sheet, h.prefix)
+	if doc, err := h.parse(d); err == nil {
+		dir := path.Join(h.prefix, path.Dir(d.URL()))
+		if err := prerender(data, d, doc, render.Options{Dir: dir, Active: active}); err != nil {
+			h.opts.Logger.Println(err)
+		}
+	}
+	if err := h.t.ExecuteTemplate(w, "index.html", data); err != nil {
+		h.opts.Logger.Println(err)
+	}
+}
+
+// prerender adds the html rendering of doc, the document of the file d, to
+// the index page data. The document is embedded as well, so the frontend
+// renders it without fetching it again.
+func prerender(data map[string]interface{}, d *models.File, doc *models.Doc, opts render.Options) error {
+	var body, enc bytes.Buffer
+	var err error
+	if d.IsSlide() {
+		err = render.Slides(&body, doc, opts)
+	} else {
+		err = render.Article(&body, doc, opts)
+	}
+	if err != nil {
+		return err
+	}
+	// The encoder escapes <, > and &, so the json can't end the script.
+	if err := models.Encode(&enc, doc); err != nil {
+		return err
+	}
+	data["body"] = template.HTML(body.String())
+	data["data"] = template.JS(enc.String())
+	return nil
+}
+
+// servePage serves the index page for directories, slides and articles and the
+// raw contents of other files.
+func (h *Handler) servePage(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Path
+	if strings.HasSuffix(u, "/") {
+		// It is a directory listing.
+		dir := strings.TrimSuffix(u, "/")
+		if doc, ok := h.cache.Load(dir); ok {
+			h.render(w, doc, dirSheet)
 			return
 		}
-		ext := filepath.Ext(u)
-		switch ext {
-		case ".article":
-			if doc, ok := cache.Load(u); ok {
-				t.ExecuteTemplate(w, "index.html", map[string]interface{}{
-					"doc":   doc,
-					"sheet": articleSheet,
-				})
-				return
-			}
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	active := 0
+	if deck, n, ok := splitSlide(u); ok {
+		// A deep link to a slide renders the deck, the UI shows the slide.
+		u, active = deck, n
+	}
+	ext := filepath.Ext(u)
+	switch ext {
+	case ".article":
+		if doc, ok := h.cache.Load(u); ok {
+			h.renderDoc(w, doc.(*models.File), articleSheet, 0)
 			return
-		case ".slide":
-			if doc, ok := cache.Load(u); ok {
-				t.ExecuteTemplate(w, "index.html", map[string]interface{}{
-					"doc":   doc,
-					"sheet": slideSheet,
-				})
-				return
-			}
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		}
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case ".slide":
+		if doc, ok := h.cache.Load(u); ok {
+			h.renderDoc(w, doc.(*models.File), slideSheet, active)
 			return
-		case "":
-			if doc, ok := cache.Load(u); ok {
-				t.ExecuteTemplate(w, "index.html", map[string]interface{}{
-					"doc":   doc,
-					"sheet": dirSheet,
-				})
-				return
-			}
-			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		}
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	case "":
+		if doc, ok := h.cache.Load(u); ok {
+			h.render(w, doc, dirSheet)
 			return
 		}
-		http.StripPrefix(basePath, fileServer).ServeHTTP(w, r)
-	}))
-	mux.Handle("/files/", http.StripPrefix("/files", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := r.URL.Path
-		if v, ok := cache.Load(u); ok {
-			d := v.(*models.File)
-			if d.IsDir {
-				http.Error(w, "bad request", http.StatusBadRequest)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	h.serveRaw(w, r)
+}
+
+// serveRaw serves the contents of a file as it is. Unless the root is trusted
+// pages are sandboxed, html files in it must not run scripts on the origin of
+// the decks.
+func (h *Handler) serveRaw(w http.ResponseWriter, r *http.Request) {
+	if !h.opts.TrustHTML {
+		w.Header().Set("Content-Security-Policy", "sandbox")
+	}
+	http.FileServer(http.FS(h.fsys)).ServeHTTP(w, r)
+}
+
+// splitSlide splits the url of a slide of a deck, like /talks/intro.slide/12,
+// in the url of the deck and the slide number.
+func splitSlide(u string) (deck string, n int, ok bool) {
+	deck, num := path.Split(u)
+	deck = strings.TrimSuffix(deck, "/")
+	if path.Ext(deck) != ".slide" {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return deck, n, true
+}
+
+// serveFile serves the parsed documents and the raw contents of other files.
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request) {
+	u := r.URL.Path
+	if v, ok := h.cache.Load(u); ok {
+		d := v.(*models.File)
+		if d.IsDir {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ext := filepath.Ext(d.Name)
+		switch ext {
+		case ".slide", ".article":
+			dc, err := h.parse(d)
+			if err != nil {
+				h.writeError(w, d, err)
 				return
 			}
-			ext := filepath.Ext(d.Name)
-			switch ext {
-			case ".slide", ".article":
-				f, err := os.Open(d.Path())
-				if err != nil {
-				}
-				defer f.Close()
-				dc, err := present.Parse(f, d.Path(), 0)
-				if err != nil {
-				}
-				err = models.Encode(w, dc)
-				if err != nil {
-					log.Println(err)
-				}
-				return
-			default:
-				http.ServeFile(w, r, d.Path())
-				return
+			w.Header().Set("Content-Type", "application/json")
+			err = models.Encode(w, dc)
+			if err != nil {
+				h.opts.Logger.Println(err)
 			}
+			return
+		default:
+			r.URL.Path = d.URL()
+			h.serveRaw(w, r)
+			return
 		}
+	}
+	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+}
+
+// serveTags renders the page listing the tags, or the documents tagged with the
+// tag named in the path.
+func (h *Handler) serveTags(w http.ResponseWriter, r *http.Request) {
+	v, _ := h.cache.Load("/")
+	root := v.(*models.File)
+	tag := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tags/"), "/")
+	if tag != "" && len(root.Tagged(tag)) == 0 {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-	})))
-	return http.ListenAndServe(":8080", mux)
+		return
+	}
+	h.render(w, tagPage(tag), dirSheet)
+}
+
+// tagPage is rendered in place of a file for the tag pages, it names the route
+// handled by the frontend.
+type tagPage string
+
+// URL returns the route of the page listing the documents tagged with t, or
+// all the tags if t is empty.
+func (t tagPage) URL() string {
+	if t == "" {
+		return "/tags"
+	}
+	return "/tags/" + url.PathEscape(string(t))
+}
+
+func (t tagPage) BaseName() string {
+	if t == "" {
+		return "tags"
+	}
+	return string(t)
+}
+
+// parse parses the document d as it is sent to the browser.
+func (h *Handler) parse(d *models.File) (*models.Doc, error) {
+	f, err := h.fsys.Open(d.Path())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	ctx := present.Context{ReadFile: present.ReadFS(h.fsys), Play: h.opts.Play, TrustHTML: h.opts.TrustHTML}
+	doc, err := ctx.Parse(f, d.Path(), h.opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if !h.opts.Notes {
+		stripNotes(doc)
+	}
+	return doc, nil
+}
+
+// sourceContext is the number of lines shown before and after the line with a
+// parse error.
+const sourceContext = 3
+
+// writeError writes err, which occurred while serving d, as a json encoded
+// models.Error. Parse errors are sent with the lines surrounding the offending
+// line so the browser can point at it.
+func (h *Handler) writeError(w http.ResponseWriter, d *models.File, err error) {
+	status := http.StatusInternalServerError
+	e, ok := err.(*models.Error)
+	switch {
+	case ok:
+		status = http.StatusUnprocessableEntity
+		e.Source = h.source(e.File, e.Line)
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+		e = &models.Error{File: d.Path(), Msg: http.StatusText(status)}
+	default:
+		h.opts.Logger.Println(err)
+		e = &models.Error{File: d.Path(), Msg: http.StatusText(status)}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	WriteJson(w, e)
+}
+
+// source returns the lines surrounding line in the file name.
+func (h *Handler) source(name string, line int) []models.SourceLine {
+	b, err := fs.ReadFile(h.fsys, name)
+	if err != nil {
+		return nil
+	}
+	var o []models.SourceLine
+	for i, text := range strings.Split(string(b), "\n") {
+		n := i + 1
+		if n >= line-sourceContext && n <= line+sourceContext {
+			o = append(o, models.SourceLine{N: n, Text: text})
+		}
+	}
+	return o
+}
+
+// sameOrigin returns true if r was sent by the frontend of the server. Requests
+// that run code or write files must come from it, otherwise any page the
+// presenter visits could post to the server. The frontend sets the
+// X-Requested-With header, which pages of other origins can't set without a
+// CORS preflight the server never allows, and browsers send the Origin of
+// cross-site posts.
+func sameOrigin(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") == "" {
+		return false
+	}
+	o := r.Header.Get("Origin")
+	if o == "" {
+		return true
+	}
+	u, err := url.Parse(o)
+	return err == nil && u.Host == r.Host
+}
+
+// stripNotes removes the presenter notes from doc.
+func stripNotes(doc *models.Doc) {
+	doc.TitleNotes = nil
+	for i := range doc.Sections {
+		doc.Sections[i] = stripSectionNotes(doc.Sections[i])
+	}
+}
+
+func stripSectionNotes(s models.Section) models.Section {
+	s.Notes = nil
+	for i, e := range s.Elem {
+		if sub, ok := e.(models.Section); ok {
+			s.Elem[i] = stripSectionNotes(sub)
+		}
+	}
+	return s
+}
+
+// Config configures the standalone server started by Server.
+type Config struct {
+	Options
+
+	// Addr is the address to listen on, defaults to :8080.
+	Addr string
+
+	// TLSCert and TLSKey are the paths to the certificate and key files. When
+	// both are set the server listens for HTTPS connections.
+	TLSCert string
+	TLSKey  string
+}
+
+// Server serves the directory in cfg.Root until the listener fails.
+func Server(cfg Config) error {
+	if (cfg.TLSCert == "") != (cfg.TLSKey == "") {
+		return errors.New("both --tls-cert and --tls-key must be specified to enable TLS")
+	}
+	if cfg.Addr == "" {
+		cfg.Addr = ":8080"
+	}
+	h, err := New(cfg.Options)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	if cfg.TLSCert != "" {
+		return http.ListenAndServeTLS(cfg.Addr, cfg.TLSCert, cfg.TLSKey, h)
+	}
+	return http.ListenAndServe(cfg.Addr, h)
+}
+
+// cleanBasePath returns p with a leading and trailing slash.
+func cleanBasePath(p string) string {
+	p = path.Clean("/" + p)
+	if p != "/" {
+		p += "/"
+	}
+	return p
+}
+
+// mountAt only lets through requests with the prefix, and redirects the prefix
+// without the trailing slash to the app's root.
+func mountAt(prefix string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == strings.TrimSuffix(prefix, "/"):
+			http.Redirect(w, r, prefix, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, prefix):
+			h.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// index returns the data used to render indexTpl for doc.
+func index(doc interface{}, sheet, base string) map[string]interface{} {
+	return map[string]interface{}{
+		"doc":    doc,
+		"sheet":  sheet,
+		"base":   base,
+		"static": false,
+	}
+}
+
+// Refresh replaces the contents of cache with the tree rooted at dir. Entries
+// for files that are no longer in the tree are removed.
+func Refresh(cache *sync.Map, dir *models.File) {
+	fresh := &sync.Map{}
+	fresh.Store("/", dir)
+	dir.Cache(fresh)
+	cache.Range(func(k, v interface{}) bool {
+		if _, ok := fresh.Load(k); !ok {
+			cache.Delete(k)
+		}
+		return true
+	})
+	fresh.Range(func(k, v interface{}) bool {
+		cache.Store(k, v)
+		return true
+	})
 }
 
 func WriteJson(o io.Writer, v interface{}) error {
@@ -191,8 +720,10 @@ func Slide(t *template.Template, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func LoadChildren(d *models.File) (*models.File, error) {
-	o, err := ioutil.ReadDir(d.Path())
+// LoadChildren reads the directory d from fsys and adds the files it contains
+// to d.Children.
+func LoadChildren(fsys fs.FS, d *models.File) (*models.File, error) {
+	o, err := fs.ReadDir(fsys, d.Path())
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +732,7 @@ func LoadChildren(d *models.File) (*models.File, error) {
 			if !info.IsDir() && !matchExt(filepath.Ext(info.Name())) {
 				continue
 			}
-			c, err := loadIInfo(d, info)
+			c, err := loadIInfo(fsys, d, info)
 			if err != nil {
 				return nil, err
 			}
@@ -213,26 +744,55 @@ func LoadChildren(d *models.File) (*models.File, error) {
 	return d, nil
 }
 
+// Load returns the tree of files rooted at the directory path. The names of the
+// files are relative to the parent of path.
 func Load(path string) (*models.File, error) {
-	stat, err := os.Stat(path)
+	path = filepath.Clean(path)
+	return LoadFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+}
+
+// LoadFS returns the tree of files rooted at the directory root in fsys. The
+// names of the files are relative to the root of fsys.
+func LoadFS(fsys fs.FS, root string) (*models.File, error) {
+	stat, err := fs.Stat(fsys, root)
 	if err != nil {
 		return nil, err
 	}
-	return loadIInfo(nil, stat)
+	d := &models.File{IsDir: stat.IsDir(), Name: root}
+	if d.IsDir {
+		return LoadChildren(fsys, d)
+	}
+	d.Meta = loadMeta(fsys, d)
+	return d, nil
 }
 
-func loadIInfo(parent *models.File, info os.FileInfo) (*models.File, error) {
+func loadIInfo(fsys fs.FS, parent *models.File, info fs.DirEntry) (*models.File, error) {
 	name := info.Name()
 	if parent != nil {
-		name = filepath.Join(parent.Name, info.Name())
+		name = path.Join(parent.Name, info.Name())
 	}
 	child := &models.File{IsDir: info.IsDir(), Name: name}
 	if child.IsDir {
-		return LoadChildren(child)
+		return LoadChildren(fsys, child)
 	}
+	child.Meta = loadMeta(fsys, child)
 	return child, nil
 }
 
+// loadMeta parses the header of the slide or article d. It returns nil for
+// other files and when the header can't be parsed, the error is reported when
+// the document itself is opened.
+func loadMeta(fsys fs.FS, d *models.File) *models.Meta {
+	if !d.IsSlide() && !d.IsArticle() {
+		return nil
+	}
+	doc, err := present.ParseFS(fsys, d.Path(), present.TitlesOnly)
+	if err != nil {
+		return nil
+	}
+	return models.NewMeta(doc)
+}
+
 func matchExt(ext string) bool {
 	switch ext {
 	case ".article", ".slide", ".go":
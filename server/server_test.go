@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+const testSlide = `Title
+Subtitle
+
+Author
+
+* Section
+
+Some text
+
+: a presenter note
+`
+
+// postRequest returns a request posting body to path the way the frontend
+// does.
+func postRequest(path, body string) *http.Request {
+	r := httptest.NewRequest("POST", path, strings.NewReader(body))
+	r.Header.Set("X-Requested-With", "XMLHttpRequest")
+	r.Header.Set("Origin", "http://"+r.Host)
+	return r
+}
+
+func newTestHandler(t *testing.T, opts Options) *Handler {
+	dir, err := ioutil.TempDir("", "vectypresent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	root := filepath.Join(dir, "talks")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "test.slide"), []byte(testSlide), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts.Root = root
+	h, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestHandler(t *testing.T) {
+	h := newTestHandler(t, Options{BasePath: "/present"})
+	var tests = []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/present", http.StatusMovedPermanently, ""},
+		{"/present/", http.StatusOK, `href="/present/static/dir.css"`},
+		{"/present/context", http.StatusOK, `"Name":"talks/test.slide"`},
+		{"/present/talks/test.slide", http.StatusOK, `href="/present/static/styles.css"`},
+		{"/present/talks/missing.slide", http.StatusNotFound, ""},
+		{"/present/talks/test.slide", http.StatusOK, `<article class="current">` + "\n" + `<h1>Title</h1>`},
+		{"/present/talks/test.slide", http.StatusOK, `<script type="application/json" id="vectypresent-doc" data-route="/talks/test.slide">{"version":1,`},
+		{"/present/talks/test.slide/3", http.StatusOK, `href="/present/static/styles.css"`},
+		{"/present/talks/test.slide/1", http.StatusOK, `<article class="current"><h3>Section</h3>`},
+		{"/present/talks/missing.slide/3", http.StatusNotFound, ""},
+		{"/present/talks/test.slide/x", http.StatusNotFound, ""},
+		{"/present/files/talks/missing.slide", http.StatusNotFound, ""},
+		{"/context", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d got %d", tt.path, tt.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: expected body to contain %q got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+}
+
+func TestHandlerNotes(t *testing.T) {
+	for _, notes := range []bool{false, true} {
+		h := newTestHandler(t, Options{Notes: notes})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/files/talks/test.slide", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200 got %d", w.Code)
+		}
+		doc := &models.Doc{}
+		if err := models.Decode(bytes.NewReader(w.Body.Bytes()), doc); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(doc.Sections[0].Notes) > 0; got != notes {
+			t.Errorf("notes=%v: expected notes to be sent %v", notes, got)
+		}
+	}
+}
+
+func TestHandlerFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"talks/test.slide": {Data: []byte(testSlide + "\n.code main.go\n")},
+		"talks/main.go":    {Data: []byte("package main\n")},
+	}
+	h, err := New(Options{FS: fsys, Root: "talks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/files/talks/test.slide", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d", w.Code)
+	}
+	doc := &models.Doc{}
+	if err := models.Decode(bytes.NewReader(w.Body.Bytes()), doc); err != nil {
+		t.Fatal(err)
+	}
+	code, ok := doc.Sections[0].Elem[1].(models.Code)
+	if !ok || string(code.Raw) != "package main\n" {
+		t.Errorf("expected code read from fs, got %#v", doc.Sections[0].Elem)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/files/talks/main.go", nil))
+	if w.Body.String() != "package main\n" {
+		t.Errorf("expected raw file got %q", w.Body.String())
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "sandbox" {
+		t.Errorf("expected raw files to be sandboxed got %q", got)
+	}
+}
+
+func TestHandlerParseError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"talks/test.slide": {Data: []byte(testSlide + "\n.unknown directive\n")},
+	}
+	h, err := New(Options{FS: fsys, Root: "talks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/files/talks/test.slide", nil))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	e := &models.Error{}
+	if err := json.Unmarshal(w.Body.Bytes(), e); err != nil {
+		t.Fatal(err)
+	}
+	if e.File != "talks/test.slide" || e.Line != 12 {
+		t.Errorf("expected error at talks/test.slide:12 got %s:%d", e.File, e.Line)
+	}
+	var found bool
+	for _, l := range e.Source {
+		found = found || (l.N == e.Line && l.Text == ".unknown directive")
+	}
+	if !found {
+		t.Errorf("expected source context got %#v", e.Source)
+	}
+}
+
+func TestHandlerTags(t *testing.T) {
+	fsys := fstest.MapFS{
+		"talks/a-old.slide": {Data: []byte("Old\n2 Jan 2015\nTags: go, tools\n\nAlice\n\n* Section\n")},
+		"talks/b-new.slide": {Data: []byte("New\n3 Mar 2020\nTags: go\n\nBob\n\n* Section\n")},
+		"talks/bad.slide":   {Data: []byte("")},
+	}
+	h, err := New(Options{FS: fsys, Root: "talks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var tests = []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/tags/", http.StatusOK, `"ACTIVE_ROUTE", "\/tags"`},
+		{"/tags/go", http.StatusOK, `"ACTIVE_ROUTE", "\/tags\/go"`},
+		{"/tags/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d got %d", tt.path, tt.status, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s: expected body to contain %q got %q", tt.path, tt.body, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/context", nil))
+	root := &models.File{}
+	if err := json.Unmarshal(w.Body.Bytes(), root); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range root.Tagged("go") {
+		got = append(got, f.Meta.Title+" "+strings.Join(f.Meta.Authors, ","))
+	}
+	if strings.Join(got, "|") != "New Bob|Old Alice" {
+		t.Errorf("expected the go documents newest first got %q", got)
+	}
+	if tags := strings.Join(root.Tags(), ","); tags != "go,tools" {
+		t.Errorf("expected tags go,tools got %q", tags)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gernest/vectypresent/present"
+	"github.com/gernest/vectypresent/present/models"
+)
+
+const (
+	// maxRecordingSize limits the size of uploaded recordings.
+	maxRecordingSize = 1 << 20
+
+	// maxRecordings is the number of recordings kept for a deck, the oldest
+	// are dropped when more are saved.
+	maxRecordings = 20
+)
+
+// serveTimings serves the timing recordings of the deck at the request path.
+// GET returns the recordings, POST validates a recording and adds it to the
+// timing file of the deck.
+func (h *Handler) serveTimings(w http.ResponseWriter, r *http.Request) {
+	v, ok := h.cache.Load(r.URL.Path)
+	if !ok || !v.(*models.File).IsSlide() {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	d := v.(*models.File)
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		t, err := h.readTiming(d)
+		if err != nil {
+			h.writeError(w, d, err)
+			return
+		}
+		if _, ok := r.URL.Query()["download"]; ok {
+			name := path.Base(models.TimingName(d.Name)) + ".json"
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		WriteJson(w, t)
+	case http.MethodPost:
+		h.addRecording(w, r, d)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// readTiming reads the timing file of the deck d. A deck without recordings
+// has an empty timing.
+func (h *Handler) readTiming(d *models.File) (*models.Timing, error) {
+	t := &models.Timing{Version: models.TimingVersion, Recordings: []models.Recording{}}
+	b, err := fs.ReadFile(h.fsys, models.TimingName(d.Path()))
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// addRecording saves the posted recording to the timing file of the deck d.
+func (h *Handler) addRecording(w http.ResponseWriter, r *http.Request, d *models.File) {
+	name := models.TimingName(d.Path())
+	switch {
+	case !h.opts.Record:
+		writeStatus(w, http.StatusMethodNotAllowed, name, "saving recordings is disabled, start the server with --record")
+		return
+	case h.dir == "":
+		writeStatus(w, http.StatusMethodNotAllowed, name, "recordings can't be saved to this file system")
+		return
+	case !sameOrigin(r):
+		writeStatus(w, http.StatusForbidden, name, "recordings must be posted by the pages of the server")
+		return
+	}
+	rec := &models.Recording{}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRecordingSize)).Decode(rec); err != nil {
+		writeStatus(w, http.StatusBadRequest, name, "invalid recording: "+err.Error())
+		return
+	}
+	doc, err := present.ParseFS(h.fsys, d.Path(), 0)
+	if err != nil {
+		h.writeError(w, d, err)
+		return
+	}
+	if err := rec.Validate(len(doc.Sections)); err != nil {
+		writeStatus(w, http.StatusUnprocessableEntity, name, err.Error())
+		return
+	}
+	if rec.Created.IsZero() {
+		rec.Created = time.Now().UTC()
+	}
+
+	h.timingMu.Lock()
+	defer h.timingMu.Unlock()
+	t, err := h.readTiming(d)
+	if err != nil {
+		h.writeError(w, d, err)
+		return
+	}
+	t.Version = models.TimingVersion
+	t.Recordings = append(t.Recordings, *rec)
+	if n := len(t.Recordings) - maxRecordings; n > 0 {
+		t.Recordings = t.Recordings[n:]
+	}
+	if err := writeFileAtomic(filepath.Join(h.dir, filepath.FromSlash(name)), t); err != nil {
+		h.writeError(w, d, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	WriteJson(w, map[string]int{"index": len(t.Recordings) - 1})
+}
+
+// writeStatus responds with status and a *models.Error for the file name.
+func writeStatus(w http.ResponseWriter, status int, name, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	WriteJson(w, &models.Error{File: name, Msg: msg})
+}
+
+// writeFileAtomic writes v as indented JSON to the file name, replacing it
+// only once it is completely written.
+func writeFileAtomic(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
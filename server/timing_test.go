@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gernest/vectypresent/present/models"
+)
+
+func TestTimings(t *testing.T) {
+	h := newTestHandler(t, Options{Record: true})
+	var tests = []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"GET", "/timings/talks/test.slide", "", http.StatusOK},
+		{"GET", "/timings/talks/missing.slide", "", http.StatusNotFound},
+		{"POST", "/timings/talks/test.slide", `{"length":5000,"ticks":[{"at":0,"slide":0},{"at":2000,"slide":1}]}`, http.StatusCreated},
+		{"POST", "/timings/talks/test.slide", `{"length":5000,"ticks":[{"at":1000,"slide":2}]}`, http.StatusUnprocessableEntity},
+		{"POST", "/timings/talks/test.slide", `{"length":5000,"ticks":[{"at":3000,"slide":1},{"at":1000,"slide":0}]}`, http.StatusUnprocessableEntity},
+		{"POST", "/timings/talks/test.slide", `{"length":5000,"ticks":[{"at":6000,"slide":1}]}`, http.StatusUnprocessableEntity},
+		{"POST", "/timings/talks/test.slide", `not json`, http.StatusBadRequest},
+		{"DELETE", "/timings/talks/test.slide", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.method == "POST" {
+			r = postRequest(tt.path, tt.body)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s %s %s: expected status %d got %d", tt.method, tt.path, tt.body, tt.status, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/timings/talks/test.slide?download", nil))
+	timing := &models.Timing{}
+	if err := json.Unmarshal(w.Body.Bytes(), timing); err != nil {
+		t.Fatal(err)
+	}
+	if len(timing.Recordings) != 1 || len(timing.Recordings[0].Ticks) != 2 {
+		t.Fatalf("expected the saved recording got %+v", timing)
+	}
+	if timing.Recordings[0].Created.IsZero() {
+		t.Error("expected the creation time to be set")
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "test.timing.json") {
+		t.Errorf("expected an attachment got %q", got)
+	}
+
+	for i := 0; i < maxRecordings+5; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, postRequest("/timings/talks/test.slide", fmt.Sprintf(`{"length":%d,"ticks":[]}`, i+1)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 got %d", w.Code)
+		}
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/timings/talks/test.slide", nil))
+	timing = &models.Timing{}
+	if err := json.Unmarshal(w.Body.Bytes(), timing); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(timing.Recordings); n != maxRecordings || timing.Recordings[n-1].Length != maxRecordings+5 {
+		t.Errorf("expected the last %d recordings to be kept got %d", maxRecordings, n)
+	}
+}
+
+func TestTimingsRecord(t *testing.T) {
+	const rec = `{"length":1000,"ticks":[]}`
+	cross := postRequest("/timings/talks/test.slide", rec)
+	cross.Header.Set("Origin", "https://evil.example")
+	simple := postRequest("/timings/talks/test.slide", rec)
+	simple.Header.Del("X-Requested-With")
+	var tests = []struct {
+		record bool
+		r      *http.Request
+		status int
+	}{
+		{false, postRequest("/timings/talks/test.slide", rec), http.StatusMethodNotAllowed},
+		{true, cross, http.StatusForbidden},
+		{true, simple, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		h := newTestHandler(t, Options{Record: tt.record})
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, tt.r)
+		if w.Code != tt.status {
+			t.Errorf("record=%v origin=%q: expected status %d got %d", tt.record, tt.r.Header.Get("Origin"), tt.status, w.Code)
+		}
+	}
+}
+
+func TestTimingsReadOnly(t *testing.T) {
+	fsys := fstest.MapFS{
+		"talks/test.slide":  {Data: []byte(testSlide)},
+		"talks/test.timing": {Data: []byte(`{"version":1,"recordings":[{"length":1000,"ticks":[]}]}`)},
+	}
+	h, err := New(Options{FS: fsys, Root: "talks", Record: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/timings/talks/test.slide", nil))
+	if !strings.Contains(w.Body.String(), `"length":1000`) {
+		t.Errorf("expected the recordings read from fs got %q", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, postRequest("/timings/talks/test.slide", `{"length":1000}`))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %d got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is sent to the browser whenever files under the watched directory
+// change.
+type Event struct {
+	// Paths are the urls of the files that were added, removed or modified.
+	Paths []string `json:"paths"`
+
+	// Tree is true when files were added or removed, meaning the /context tree
+	// must be fetched again.
+	Tree bool `json:"tree"`
+
+	// Meta is true when documents were modified, their headers shown in the
+	// directory listings may have changed.
+	Meta bool `json:"meta,omitempty"`
+}
+
+// hasDocument returns true if any of the urls is a slide or article.
+func hasDocument(urls []string) bool {
+	for _, u := range urls {
+		switch path.Ext(u) {
+		case ".slide", ".article":
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher polls a directory for changes and notifies subscribed browser tabs
+// using server sent events.
+type Watcher struct {
+	fsys     fs.FS
+	root     string
+	interval time.Duration
+
+	// OnChange is called with every detected change before the event is
+	// broadcast to the clients.
+	OnChange func(*Event) error
+
+	// Logger is used to log errors, defaults to the standard logger.
+	Logger *log.Logger
+
+	mu      sync.Mutex
+	clients map[chan *Event]struct{}
+	stamps  map[string]time.Time
+	done    chan struct{}
+}
+
+// NewWatcher returns a Watcher for the directory root in fsys that checks for
+// changes every interval.
+func NewWatcher(fsys fs.FS, root string, interval time.Duration) *Watcher {
+	return &Watcher{
+		fsys:     fsys,
+		root:     root,
+		interval: interval,
+		Logger:   log.New(os.Stderr, "", log.LstdFlags),
+		clients:  make(map[chan *Event]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Watch polls for changes until done is closed. Open event streams are ended
+// when Watch returns.
+func (w *Watcher) Watch(done <-chan struct{}) error {
+	defer close(w.done)
+	stamps, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.stamps = stamps
+	tick := time.NewTicker(w.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-tick.C:
+			stamps, err := w.scan()
+			if err != nil {
+				w.Logger.Println(err)
+				continue
+			}
+			e := diff(w.stamps, stamps)
+			if e == nil {
+				continue
+			}
+			if w.OnChange != nil {
+				if err := w.OnChange(e); err != nil {
+					// The change is seen again on the next tick.
+					w.Logger.Println(err)
+					continue
+				}
+			}
+			w.stamps = stamps
+			w.broadcast(e)
+		}
+	}
+}
+
+// scan returns the modification time of every non hidden file under the
+// watched directory keyed by the file url. Timing files are left out.
+func (w *Watcher) scan() (map[string]time.Time, error) {
+	stamps := make(map[string]time.Time)
+	err := fs.WalkDir(w.fsys, w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != w.root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), ".timing") {
+			// Saving a recording must not reload the open decks.
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stamps["/"+path] = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stamps, nil
+}
+
+// diff compares two scans and returns the resulting event, or nil if nothing
+// changed.
+func diff(old, current map[string]time.Time) *Event {
+	e := &Event{}
+	for k, v := range current {
+		o, ok := old[k]
+		if !ok {
+			e.Tree = true
+			e.Paths = append(e.Paths, k)
+			continue
+		}
+		if !o.Equal(v) {
+			e.Paths = append(e.Paths, k)
+		}
+	}
+	for k := range old {
+		if _, ok := current[k]; !ok {
+			e.Tree = true
+			e.Paths = append(e.Paths, k)
+		}
+	}
+	if len(e.Paths) == 0 {
+		return nil
+	}
+	sort.Strings(e.Paths)
+	return e
+}
+
+func (w *Watcher) subscribe() chan *Event {
+	ch := make(chan *Event, 1)
+	w.mu.Lock()
+	w.clients[ch] = struct{}{}
+	w.mu.Unlock()
+	return ch
+}
+
+func (w *Watcher) unsubscribe(ch chan *Event) {
+	w.mu.Lock()
+	delete(w.clients, ch)
+	w.mu.Unlock()
+}
+
+// broadcast sends e to every client. A client that hasn't read the previous
+// event yet gets both merged into one, so no change is lost.
+func (w *Watcher) broadcast(e *Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.clients {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+		// broadcast is the only sender, so once the pending event is taken
+		// the channel has room.
+		select {
+		case pending := <-ch:
+			ch <- merge(pending, e)
+		default:
+			ch <- e
+		}
+	}
+}
+
+// merge returns an event covering the changes of a and b.
+func merge(a, b *Event) *Event {
+	e := &Event{Tree: a.Tree || b.Tree, Meta: a.Meta || b.Meta}
+	seen := make(map[string]bool)
+	for _, p := range append(append([]string{}, a.Paths...), b.Paths...) {
+		if !seen[p] {
+			seen[p] = true
+			e.Paths = append(e.Paths, p)
+		}
+	}
+	sort.Strings(e.Paths)
+	return e
+}
+
+// ServeHTTP streams change events to the client as server sent events.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+	ch := w.subscribe()
+	defer w.unsubscribe(ch)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-w.done:
+			return
+		case e := <-ch:
+			b, err := json.Marshal(e)
+			if err != nil {
+				w.Logger.Println(err)
+				continue
+			}
+			fmt.Fprintf(rw, "data: %s\n\n", b)
+			flusher.Flush()
+		}
+	}
+}
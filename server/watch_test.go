@@ -0,0 +1,87 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestWatcherBroadcast(t *testing.T) {
+	w := NewWatcher(fstest.MapFS{}, ".", time.Second)
+	ch := w.subscribe()
+	defer w.unsubscribe(ch)
+
+	// The client reads nothing until all the events are sent.
+	w.broadcast(&Event{Paths: []string{"/talks/a.slide"}})
+	w.broadcast(&Event{Paths: []string{"/talks/b.png", "/talks/a.slide"}, Tree: true})
+	w.broadcast(&Event{Paths: []string{"/talks/c.article"}, Meta: true})
+
+	want := &Event{
+		Paths: []string{"/talks/a.slide", "/talks/b.png", "/talks/c.article"},
+		Tree:  true,
+		Meta:  true,
+	}
+	select {
+	case e := <-ch:
+		if !reflect.DeepEqual(e, want) {
+			t.Errorf("expected the events merged into %+v got %+v", want, e)
+		}
+	default:
+		t.Fatal("expected a pending event")
+	}
+	select {
+	case e := <-ch:
+		t.Errorf("expected a single event got another %+v", e)
+	default:
+	}
+}
+
+func TestWatcherRetry(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "test.slide")
+	if err := ioutil.WriteFile(name, []byte(testSlide), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := NewWatcher(os.DirFS(dir), ".", 5*time.Millisecond)
+	w.Logger = log.New(ioutil.Discard, "", 0)
+	var calls int32
+	w.OnChange = func(*Event) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("reload failed")
+		}
+		return nil
+	}
+	ch := w.subscribe()
+	defer w.unsubscribe(ch)
+	done := make(chan struct{})
+	defer close(done)
+	go w.Watch(done)
+
+	// Touch the file until the first, failing, reload.
+	deadline := time.Now().Add(5 * time.Second)
+	for i := 1; atomic.LoadInt32(&calls) == 0; i++ {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the change to be detected")
+		}
+		mtime := time.Unix(int64(1000+i), 0)
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case e := <-ch:
+		if !reflect.DeepEqual(e.Paths, []string{"/test.slide"}) {
+			t.Errorf("expected the change of test.slide got %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the change to be retried and sent")
+	}
+}
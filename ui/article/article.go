@@ -1,17 +1,15 @@
 package article
 
 import (
-	"bytes"
-	"net/url"
-	"path/filepath"
+	"path"
 
 	"github.com/gopherjs/vecty/elem"
 	"github.com/gopherjs/vecty/prop"
 
 	"github.com/gernest/vectypresent/present/models"
 	"github.com/gernest/vectypresent/ui/components"
+	"github.com/gernest/vectypresent/ui/live"
 	"github.com/gernest/vectypresent/ui/util"
-	"github.com/gernest/xhr"
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
 )
@@ -24,35 +22,54 @@ type Article struct {
 	vecty.Core
 
 	doc *models.Doc
+	url string
+	err error
 }
 
 func (a *Article) Mount() {
 	location := js.Global.Get("location")
 	addStyle(location.Get("origin").String())
-	href := location.Get("href").String()
-	u, err := url.Parse(href)
-	if err != nil {
-		panic(err)
-	}
-	u.Path = filepath.Join("/files", u.Path)
-	go func() {
-		data, err := xhr.Send("GET", u.String(), nil)
-		if err != nil {
-			panic(err)
+	page := util.Location()
+	a.url = util.Path(path.Join("/files", page))
+	live.Subscribe(a.url, func(e *live.Event) {
+		if e.Affects(page) {
+			a.fetch()
 		}
-		doc := &models.Doc{}
-		err = models.Decode(bytes.NewReader(data), &doc)
-		if err != nil {
-			panic(err)
-		}
-		a.doc = doc
-		vecty.SetTitle(doc.Title)
+	})
+	go a.fetch()
+}
+
+// fetch loads the document from the server and re renders the article.
+func (a *Article) fetch() {
+	doc, err := util.GetDoc(a.url)
+	if err != nil {
+		a.err = err
 		vecty.Rerender(a)
-	}()
+		return
+	}
+	a.err = nil
+	a.doc = doc
+	vecty.SetTitle(doc.Title)
+	vecty.Rerender(a)
+	revealHash()
+}
+
+// revealHash scrolls to the element the location #fragment points at, like
+// the section a search hit links to. The browser can't do it by itself since
+// the sections are rendered after the page loads.
+func revealHash() {
+	hash := js.Global.Get("location").Get("hash").String()
+	if len(hash) < 2 {
+		return
+	}
+	el := js.Global.Get("document").Call("getElementById", hash[1:])
+	if el != nil {
+		el.Call("scrollIntoView")
+	}
 }
 
 func addStyle(origin string) {
-	slideHref := origin + articleSheet
+	slideHref := origin + util.Path(articleSheet)
 	hasSlideSheet := false
 	util.ListSheets(func(sheet *js.Object) bool {
 		href := sheet.Get("href").String()
@@ -74,11 +91,12 @@ func addStyle(origin string) {
 }
 
 func (a *Article) Unmount() {
+	live.Unsubscribe(a.url)
 	// restoreStyle(js.Global.Get("location").Get("origin").String())
 }
 
 func restoreStyle(origin string) {
-	slideHref := origin + articleSheet
+	slideHref := origin + util.Path(articleSheet)
 	util.ListSheets(func(sheet *js.Object) bool {
 		href := sheet.Get("href").String()
 		switch href {
@@ -92,6 +110,9 @@ func restoreStyle(origin string) {
 }
 
 func (a *Article) Render() vecty.ComponentOrHTML {
+	if a.err != nil {
+		return &components.ErrorPage{Err: a.err}
+	}
 	if a.doc == nil {
 		return elem.Body()
 	}
@@ -126,7 +147,7 @@ func (a *Article) Render() vecty.ComponentOrHTML {
 		),
 		elem.Div(
 			vecty.Markup(
-				vecty.Class("wide"),
+				vecty.Class("wide", a.doc.ThemeClass()),
 				prop.ID("page"),
 			),
 			elem.Div(
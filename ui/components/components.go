@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/util"
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
@@ -173,6 +174,12 @@ func RenderElem(e models.Elem) vecty.ComponentOrHTML {
 		return &Code{code: v}
 	case models.Image:
 		return &Image{img: v}
+	case models.HTML:
+		return &HTML{h: v}
+	case models.Iframe:
+		return &IFrame{frame: v}
+	case models.Video:
+		return &Video{v: v}
 	case models.Link:
 		return &Link{link: v}
 	case models.Caption:
@@ -208,6 +215,18 @@ type Code struct {
 	vecty.Core
 
 	code models.Code
+
+	// block is the rendered code block, its text is the program run by the
+	// playground.
+	block *vecty.HTML
+
+	// output holds the events of the last run, it is shown unless nil.
+	output  []playEvent
+	running bool
+
+	// runs counts the runs, so events of killed runs are dropped.
+	runs int
+	req  *js.Object
 }
 
 func (c *Code) Render() vecty.ComponentOrHTML {
@@ -215,15 +234,27 @@ func (c *Code) Render() vecty.ComponentOrHTML {
 		"code":       true,
 		"playground": c.code.Play,
 	}
-	return elem.Div(
+	c.block = elem.Div(
 		vecty.Markup(class,
 			vecty.MarkupIf(c.code.Edit,
 				vecty.Attribute("contenteditable", "true"),
 				vecty.Attribute("spellcheck", "false"),
+				// Typing in the code must not change slides.
+				event.KeyDown(func(*vecty.Event) {}).StopPropagation(),
 			),
 			vecty.UnsafeHTML(string(c.code.Text)),
 		),
 	)
+	if !c.code.Play || util.IsStatic() {
+		// Exported sites have no server to run the code.
+		return c.block
+	}
+	return elem.Div(
+		vecty.Markup(vecty.Class("play")),
+		c.block,
+		c.renderButtons(),
+		c.renderOutput(),
+	)
 }
 
 type Text struct {
@@ -254,7 +285,7 @@ func (t *Text) Render() vecty.ComponentOrHTML {
 		if k == 0 {
 			s += string(models.Style(v))
 		} else {
-			s += "<br>" + string(models.Style(s))
+			s += "<br>" + string(models.Style(v))
 		}
 	}
 	return elem.Paragraph(
@@ -269,29 +300,53 @@ type Image struct {
 }
 
 func (i *Image) Render() vecty.ComponentOrHTML {
-	img, err := url.Parse(i.img.URL)
-	if err != nil {
-		panic(err)
-	}
-	if !img.IsAbs() {
-		location := js.Global.Get("location").Get("pathname").String()
-		base := filepath.Dir(location)
-		img.Path = filepath.Join(base, i.img.URL)
-	}
 	return elem.Div(
 		vecty.Markup(vecty.Class("image")),
 		elem.Image(
 			vecty.Markup(
-				prop.Src(img.String()),
-				vecty.MarkupIf(i.img.Height != 0,
-					vecty.Attribute("height", fmt.Sprint(i.img.Height))),
-				vecty.MarkupIf(i.img.Width != 0,
-					vecty.Attribute("height", fmt.Sprint(i.img.Width))),
+				prop.Src(resolve(i.img.URL)),
+				size(i.img.Width, i.img.Height),
 			),
 		),
 	)
 }
 
+// resolve returns the url u resolved against the directory of the current
+// page, relative urls in documents are relative to the document.
+func resolve(u string) string {
+	v, err := url.Parse(u)
+	if err != nil || v.IsAbs() || strings.HasPrefix(u, "/") {
+		return u
+	}
+	location := js.Global.Get("location").Get("pathname").String()
+	v.Path = filepath.Join(filepath.Dir(location), v.Path)
+	return v.String()
+}
+
+// size sets the width and height attributes of images, frames and videos,
+// sizes that are zero are left to the browser.
+func size(width, height int) vecty.MarkupList {
+	return vecty.Markup(
+		vecty.MarkupIf(height != 0,
+			vecty.Attribute("height", fmt.Sprint(height))),
+		vecty.MarkupIf(width != 0,
+			vecty.Attribute("width", fmt.Sprint(width))),
+	)
+}
+
+// HTML renders the contents of a file included with .html.
+type HTML struct {
+	vecty.Core
+
+	h models.HTML
+}
+
+func (h *HTML) Render() vecty.ComponentOrHTML {
+	return elem.Div(
+		vecty.Markup(vecty.UnsafeHTML(string(h.h.HTML))),
+	)
+}
+
 type IFrame struct {
 	vecty.Core
 	frame models.Iframe
@@ -300,11 +355,8 @@ type IFrame struct {
 func (f *IFrame) Render() vecty.ComponentOrHTML {
 	return elem.InlineFrame(
 		vecty.Markup(
-			prop.Src(f.frame.URL),
-			vecty.MarkupIf(f.frame.Height != 0,
-				vecty.Attribute("height", fmt.Sprint(f.frame.Height))),
-			vecty.MarkupIf(f.frame.Width != 0,
-				vecty.Attribute("height", fmt.Sprint(f.frame.Width))),
+			prop.Src(resolve(f.frame.URL)),
+			size(f.frame.Width, f.frame.Height),
 		),
 	)
 }
@@ -322,21 +374,17 @@ func (v *Video) Render() vecty.ComponentOrHTML {
 		),
 		elem.Video(
 			vecty.Markup(
-				vecty.MarkupIf(v.v.Height != 0,
-					vecty.Attribute("height", fmt.Sprint(v.v.Height))),
-				vecty.MarkupIf(v.v.Width != 0,
-					vecty.Attribute("height", fmt.Sprint(v.v.Width))),
+				size(v.v.Width, v.v.Height),
 				vecty.Attribute("controls", ""),
 			),
 			elem.Source(
 				vecty.Markup(
-					prop.Src(v.v.URL),
+					prop.Src(resolve(v.v.URL)),
 					vecty.Attribute("type", v.v.SourceType),
 				),
 			),
 		),
 	)
-
 }
 
 type Link struct {
@@ -383,6 +431,48 @@ func (*Spinner) Render() vecty.ComponentOrHTML {
 	)
 }
 
+// ErrorPage is shown instead of a document that couldn't be loaded. Errors
+// positioned in the document source show the lines around the offending line.
+type ErrorPage struct {
+	vecty.Core
+
+	Err error
+}
+
+func (p *ErrorPage) Render() vecty.ComponentOrHTML {
+	e, ok := p.Err.(*models.Error)
+	if !ok {
+		return elem.Body(
+			elem.Div(
+				vecty.Markup(vecty.Class("error-page")),
+				elem.Heading2(vecty.Text("Error")),
+				elem.Paragraph(vecty.Text(p.Err.Error())),
+			),
+		)
+	}
+	title := e.File
+	if e.Line > 0 {
+		title = fmt.Sprintf("%s:%d", e.File, e.Line)
+	}
+	var lines vecty.List
+	for _, l := range e.Source {
+		lines = append(lines, elem.Span(
+			vecty.Markup(
+				vecty.ClassMap{"error-line": l.N == e.Line},
+			),
+			vecty.Text(fmt.Sprintf("%4d  %s\n", l.N, l.Text)),
+		))
+	}
+	return elem.Body(
+		elem.Div(
+			vecty.Markup(vecty.Class("error-page")),
+			elem.Heading2(vecty.Text(title)),
+			elem.Paragraph(vecty.Text(e.Msg)),
+			vecty.If(len(lines) > 0, elem.Preformatted(lines)),
+		),
+	)
+}
+
 type TOC struct {
 	vecty.Core
 
@@ -0,0 +1,197 @@
+package components
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+// playEvent is streamed by the server while a program runs, see
+// server.PlayEvent.
+type playEvent struct {
+	Kind string `json:"kind"`
+	Body string `json:"body"`
+}
+
+// class returns the css class of the output of the event.
+func (e playEvent) class() string {
+	switch e.Kind {
+	case "stdout":
+		return "stdout"
+	case "stderr", "build":
+		return "stderr"
+	default:
+		return "system"
+	}
+}
+
+// run sends the program to the server and shows its output as it is
+// streamed back. The hidden prefix and suffix of the snippet are part of the
+// text of the block, so they are sent too.
+func (c *Code) run() {
+	c.kill()
+	c.runs++
+	n := c.runs
+	src := c.block.Node().Get("textContent").String()
+	c.output = []playEvent{}
+	c.running = true
+	vecty.Rerender(c)
+
+	req := js.Global.Get("XMLHttpRequest").New()
+	c.req = req
+	read := 0
+	flush := func() {
+		text := req.Get("responseText").String()
+		for {
+			i := strings.IndexByte(text[read:], '\n')
+			if i < 0 {
+				break
+			}
+			var e playEvent
+			if err := json.Unmarshal([]byte(text[read:read+i]), &e); err == nil {
+				if e.Kind == "end" {
+					e = exited(e.Body)
+				}
+				c.add(e)
+			}
+			read += i + 1
+		}
+	}
+	req.Call("addEventListener", "progress", func(*js.Object) {
+		if n == c.runs {
+			flush()
+			vecty.Rerender(c)
+		}
+	})
+	req.Call("addEventListener", "loadend", func(*js.Object) {
+		if n != c.runs {
+			return
+		}
+		flush()
+		if status := req.Get("status").Int(); status != 200 {
+			c.add(playEvent{Kind: "system", Body: "The server can't run code, start it with --play."})
+		}
+		c.running = false
+		c.req = nil
+		vecty.Rerender(c)
+	})
+	req.Call("open", "POST", util.Path("/compile"), true)
+	req.Call("setRequestHeader", "X-Requested-With", "XMLHttpRequest")
+	req.Call("send", src)
+}
+
+// exited returns the message shown when a program ends, err is the reason it
+// failed if it did.
+func exited(err string) playEvent {
+	if err == "" {
+		return playEvent{Kind: "system", Body: "\nProgram exited."}
+	}
+	return playEvent{Kind: "system", Body: "\nProgram exited: " + err + "."}
+}
+
+// add appends e to the output, joining it to the last event of the same kind.
+func (c *Code) add(e playEvent) {
+	if n := len(c.output); n > 0 && c.output[n-1].Kind == e.Kind {
+		c.output[n-1].Body += e.Body
+		return
+	}
+	c.output = append(c.output, e)
+}
+
+// kill stops the running program, the server kills it once the request is
+// canceled.
+func (c *Code) kill() {
+	if c.req == nil {
+		return
+	}
+	c.runs++
+	c.req.Call("abort")
+	c.req = nil
+	c.running = false
+	c.add(playEvent{Kind: "system", Body: "\nProgram killed."})
+}
+
+// format replaces the visible code with its formatted version. The hidden
+// prefix and suffix are left alone, snippets are formatted on their own.
+func (c *Code) format() {
+	pre := c.block.Node().Call("querySelector", "pre:not([style])")
+	if pre == nil || pre == js.Undefined {
+		return
+	}
+	status, data, err := util.Post(util.Path("/fmt"), []byte(pre.Get("textContent").String()))
+	if err != nil || status != 200 {
+		c.output = []playEvent{{Kind: "system", Body: "The server can't format code, start it with --play."}}
+		vecty.Rerender(c)
+		return
+	}
+	var res struct {
+		Body  string `json:"body"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		println(err.Error())
+		return
+	}
+	if res.Error != "" {
+		c.output = []playEvent{{Kind: "stderr", Body: res.Error}}
+		vecty.Rerender(c)
+		return
+	}
+	pre.Set("textContent", res.Body)
+}
+
+func (c *Code) renderButtons() vecty.ComponentOrHTML {
+	var run *vecty.HTML
+	if c.running {
+		run = button("Kill", func() {
+			c.kill()
+			vecty.Rerender(c)
+		})
+	} else {
+		run = button("Run", func() { go c.run() })
+	}
+	return elem.Div(
+		vecty.Markup(vecty.Class("buttons")),
+		run,
+		button("Format", func() { go c.format() }),
+	)
+}
+
+func (c *Code) renderOutput() vecty.ComponentOrHTML {
+	if c.output == nil {
+		return nil
+	}
+	var spans vecty.List
+	for _, e := range c.output {
+		spans = append(spans, elem.Span(
+			vecty.Markup(vecty.Class(e.class())),
+			vecty.Text(e.Body),
+		))
+	}
+	return elem.Div(
+		vecty.Markup(vecty.Class("output")),
+		elem.Preformatted(spans),
+		elem.Div(
+			vecty.Markup(vecty.Class("buttons")),
+			button("Close", func() {
+				c.kill()
+				c.output = nil
+				vecty.Rerender(c)
+			}),
+		),
+	)
+}
+
+func button(label string, onClick func()) *vecty.HTML {
+	return elem.Button(
+		vecty.Markup(
+			event.Click(func(*vecty.Event) { onClick() }),
+		),
+		vecty.Text(label),
+	)
+}
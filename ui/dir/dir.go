@@ -3,6 +3,7 @@ package dir
 import (
 	"github.com/gernest/vectypresent/present/models"
 	"github.com/gernest/vectypresent/ui/router"
+	"github.com/gernest/vectypresent/ui/util"
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
 	"github.com/gopherjs/vecty/event"
@@ -15,6 +16,14 @@ type Dir struct {
 	Dir *models.File `vecty:"prop"`
 
 	Router *router.Router
+
+	// query is the text of the search box, result holds its hits.
+	query  string
+	result *models.SearchResult
+
+	// tag filters the documents listed, byDate sorts them newest first.
+	tag    string
+	byDate bool
 }
 
 func (d *Dir) Mount() {
@@ -38,27 +47,15 @@ func (d *Dir) Render() vecty.ComponentOrHTML {
 			filesList = append(filesList, child)
 		}
 	}
+	articleList = d.filter(articleList)
+	slideList = d.filter(slideList)
 	var list vecty.List
 	if len(articleList) > 0 {
 		list = append(list, elem.Heading4(
 			vecty.Text("Articles :"),
 		))
 		for _, child := range articleList {
-			url := child.URL()
-			println(url)
-			list = append(list, elem.Description(
-				elem.Description(
-					elem.Anchor(
-						vecty.Markup(
-							prop.Href(child.URL()),
-							event.Click(func(e *vecty.Event) {
-								d.Router.PushState(url)
-							}).PreventDefault(),
-						),
-						vecty.Text(child.BaseName()),
-					),
-				),
-			))
+			list = append(list, renderDoc(child, d.Router))
 		}
 	}
 	if len(slideList) > 0 {
@@ -66,20 +63,7 @@ func (d *Dir) Render() vecty.ComponentOrHTML {
 			vecty.Text("Slide decks:"),
 		))
 		for _, child := range slideList {
-			url := child.URL()
-			list = append(list, elem.Description(
-				elem.Description(
-					elem.Anchor(
-						vecty.Markup(
-							prop.Href(child.URL()),
-							event.Click(func(e *vecty.Event) {
-								d.Router.PushState(url)
-							}).PreventDefault(),
-						),
-						vecty.Text(child.BaseName()),
-					),
-				),
-			))
+			list = append(list, renderDoc(child, d.Router))
 		}
 	}
 	if len(dirList) > 0 {
@@ -92,7 +76,7 @@ func (d *Dir) Render() vecty.ComponentOrHTML {
 				elem.Description(
 					elem.Anchor(
 						vecty.Markup(
-							prop.Href(child.URL()),
+							prop.Href(util.Page(url)),
 							event.Click(func(e *vecty.Event) {
 								d.Router.PushState(url)
 							}).PreventDefault(),
@@ -113,7 +97,7 @@ func (d *Dir) Render() vecty.ComponentOrHTML {
 				elem.Description(
 					elem.Anchor(
 						vecty.Markup(
-							prop.Href(url),
+							prop.Href(util.Path(url)),
 						),
 						vecty.Text(child.BaseName()),
 					),
@@ -126,6 +110,8 @@ func (d *Dir) Render() vecty.ComponentOrHTML {
 			prop.ID("page"),
 		),
 		elem.Heading2(vecty.Text(d.Dir.BaseName())),
+		d.renderSearch(),
+		d.renderFilters(),
 		elem.DescriptionList(list),
 	)
 }
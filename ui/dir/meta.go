@@ -0,0 +1,159 @@
+package dir
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/router"
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// TagRoute returns the route of the page listing the documents tagged with
+// tag, or all the tags if tag is empty.
+func TagRoute(tag string) string {
+	if tag == "" {
+		return "/tags"
+	}
+	return "/tags/" + url.PathEscape(tag)
+}
+
+// filter returns the documents matching the tag filter, in the selected order.
+func (d *Dir) filter(files []*models.File) []*models.File {
+	var list []*models.File
+	for _, f := range files {
+		if d.tag == "" || (f.Meta != nil && f.Meta.HasTag(d.tag)) {
+			list = append(list, f)
+		}
+	}
+	if d.byDate {
+		models.SortByTime(list)
+	}
+	return list
+}
+
+// renderFilters renders the controls filtering the documents by tag and
+// sorting them. Nothing is rendered for directories without tagged documents
+// or dates.
+func (d *Dir) renderFilters() vecty.ComponentOrHTML {
+	seen := make(map[string]bool)
+	var tags []string
+	dated := false
+	for _, f := range d.Dir.Children {
+		if f.Meta == nil {
+			continue
+		}
+		dated = dated || !f.Meta.Time.IsZero()
+		for _, t := range f.Meta.Tags {
+			if !seen[t] {
+				seen[t] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	if len(tags) == 0 && !dated {
+		return nil
+	}
+	options := vecty.List{option("", "All tags", d.tag == "")}
+	for _, t := range tags {
+		options = append(options, option(t, t, d.tag == t))
+	}
+	return elem.Div(
+		vecty.Markup(vecty.Class("filters")),
+		elem.Select(
+			vecty.Markup(
+				event.Change(func(e *vecty.Event) {
+					d.tag = e.Target.Get("value").String()
+					vecty.Rerender(d)
+				}),
+			),
+			options,
+		),
+		elem.Select(
+			vecty.Markup(
+				event.Change(func(e *vecty.Event) {
+					d.byDate = e.Target.Get("value").String() == "date"
+					vecty.Rerender(d)
+				}),
+			),
+			option("name", "By name", !d.byDate),
+			option("date", "Newest first", d.byDate),
+		),
+		elem.Anchor(
+			vecty.Markup(
+				prop.Href(util.Page(TagRoute(""))),
+				event.Click(func(e *vecty.Event) {
+					d.Router.PushState(TagRoute(""))
+				}).PreventDefault(),
+			),
+			vecty.Text("All tags"),
+		),
+	)
+}
+
+func option(value, text string, selected bool) *vecty.HTML {
+	return elem.Option(
+		vecty.Markup(
+			prop.Value(value),
+			vecty.Property("selected", selected),
+		),
+		vecty.Text(text),
+	)
+}
+
+// renderDoc renders the entry of a slide or article, its title links to the
+// document and its header details are shown below it.
+func renderDoc(f *models.File, r *router.Router) vecty.ComponentOrHTML {
+	route := f.URL()
+	title := f.BaseName()
+	var details vecty.List
+	if m := f.Meta; m != nil {
+		if m.Title != "" {
+			title = m.Title
+		}
+		var info []string
+		if !m.Time.IsZero() {
+			info = append(info, m.Time.Format(models.TimeFormat))
+		}
+		if len(m.Authors) > 0 {
+			info = append(info, strings.Join(m.Authors, ", "))
+		}
+		if len(info) > 0 {
+			details = append(details, vecty.Text(strings.Join(info, " · ")))
+		}
+		for _, t := range m.Tags {
+			tag := TagRoute(t)
+			details = append(details, elem.Anchor(
+				vecty.Markup(
+					vecty.Class("tag"),
+					prop.Href(util.Page(tag)),
+					event.Click(func(e *vecty.Event) {
+						r.PushState(tag)
+					}).PreventDefault(),
+				),
+				vecty.Text(t),
+			))
+		}
+	}
+	return elem.Description(
+		elem.Description(
+			elem.Anchor(
+				vecty.Markup(
+					prop.Href(util.Page(route)),
+					event.Click(func(e *vecty.Event) {
+						r.PushState(route)
+					}).PreventDefault(),
+				),
+				vecty.Text(title),
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("meta")),
+				details,
+			),
+		),
+	)
+}
@@ -0,0 +1,112 @@
+package dir
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// search fetches the hits for q and re renders the directory with them.
+// Responses to queries that were replaced while they were in flight are
+// dropped.
+func (d *Dir) search(q string) {
+	if strings.TrimSpace(q) == "" {
+		d.result = nil
+		vecty.Rerender(d)
+		return
+	}
+	status, data, err := util.Get(util.Path("/search?q=" + url.QueryEscape(q)))
+	if err != nil || status != 200 {
+		println(fmt.Sprintf("searching: status %d %v", status, err))
+		return
+	}
+	res := &models.SearchResult{}
+	if err := json.Unmarshal(data, res); err != nil {
+		println(err.Error())
+		return
+	}
+	if res.Query != d.query {
+		return
+	}
+	d.result = res
+	vecty.Rerender(d)
+}
+
+// hitRoute returns the route of the part of the document matched by hit, the
+// slide of a deck or the section of an article.
+func hitRoute(hit models.SearchHit) string {
+	if len(hit.Section) == 0 {
+		return hit.File
+	}
+	if path.Ext(hit.File) == ".slide" {
+		return hit.File + "#" + strconv.Itoa(hit.Section[0])
+	}
+	s := models.Section{Number: hit.Section}
+	return hit.File + "#TOC_" + s.FormattedNumber()
+}
+
+// renderSearch renders the search box, and the hits of the last search.
+func (d *Dir) renderSearch() vecty.ComponentOrHTML {
+	if util.IsStatic() {
+		// Exported sites have no server to search.
+		return nil
+	}
+	input := elem.Input(
+		vecty.Markup(
+			vecty.Class("search"),
+			prop.Type(prop.TypeSearch),
+			prop.Placeholder("Search"),
+			prop.Value(d.query),
+			event.Input(func(e *vecty.Event) {
+				d.query = e.Target.Get("value").String()
+				go d.search(d.query)
+			}),
+		),
+	)
+	if d.result == nil {
+		return elem.Div(input)
+	}
+	var hits vecty.List
+	for _, hit := range d.result.Hits {
+		route := hitRoute(hit)
+		title := hit.Title
+		if hit.SectionTitle != "" {
+			title += " › " + hit.SectionTitle
+		}
+		hits = append(hits, elem.ListItem(
+			elem.Anchor(
+				vecty.Markup(
+					prop.Href(util.Page(hit.File)+strings.TrimPrefix(route, hit.File)),
+					event.Click(func(e *vecty.Event) {
+						d.Router.PushState(route)
+					}).PreventDefault(),
+				),
+				vecty.Text(title),
+			),
+			elem.Paragraph(
+				vecty.Markup(vecty.Class("search-snippet")),
+				vecty.Text(hit.Snippet),
+			),
+		))
+	}
+	if len(hits) == 0 {
+		hits = append(hits, elem.ListItem(vecty.Text("No results")))
+	}
+	return elem.Div(
+		input,
+		elem.UnorderedList(
+			vecty.Markup(vecty.Class("search-hits")),
+			hits,
+		),
+	)
+}
@@ -0,0 +1,70 @@
+package dir
+
+import (
+	"strconv"
+
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/router"
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+	"github.com/gopherjs/vecty/prop"
+)
+
+// Tags lists the documents tagged with Tag, newest first. All the tags are
+// listed when Tag is empty.
+type Tags struct {
+	vecty.Core
+
+	Tag  string       `vecty:"prop"`
+	Root *models.File `vecty:"prop"`
+
+	Router *router.Router
+}
+
+func (t *Tags) Mount() {
+	if t.Tag == "" {
+		vecty.SetTitle("tags")
+		return
+	}
+	vecty.SetTitle(t.Tag)
+}
+
+func (t *Tags) Render() vecty.ComponentOrHTML {
+	var list vecty.List
+	heading := "Tags"
+	if t.Tag == "" {
+		for _, tag := range t.Root.Tags() {
+			route := TagRoute(tag)
+			n := len(t.Root.Tagged(tag))
+			list = append(list, elem.Description(
+				elem.Anchor(
+					vecty.Markup(
+						prop.Href(util.Page(route)),
+						event.Click(func(e *vecty.Event) {
+							t.Router.PushState(route)
+						}).PreventDefault(),
+					),
+					vecty.Text(tag),
+				),
+				vecty.Text(" ("+strconv.Itoa(n)+")"),
+			))
+		}
+	} else {
+		heading = "Tagged " + t.Tag
+		for _, f := range t.Root.Tagged(t.Tag) {
+			list = append(list, renderDoc(f, t.Router))
+		}
+	}
+	if len(list) == 0 {
+		list = append(list, elem.Description(vecty.Text("Nothing is tagged yet.")))
+	}
+	return elem.Div(
+		vecty.Markup(
+			prop.ID("page"),
+		),
+		elem.Heading2(vecty.Text(heading)),
+		elem.DescriptionList(list),
+	)
+}
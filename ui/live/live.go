@@ -0,0 +1,85 @@
+// Package live listens for change notifications sent by the server when it is
+// started in watch mode.
+package live
+
+import (
+	"encoding/json"
+	"path"
+	"sync"
+
+	"github.com/gopherjs/gopherjs/js"
+)
+
+// Event mirrors the change notification sent by the server.
+type Event struct {
+	Paths []string `json:"paths"`
+	Tree  bool     `json:"tree"`
+	Meta  bool     `json:"meta"`
+}
+
+// Affects returns true if the file at url needs to be reloaded. Changes to any
+// file in the same directory count, since decks include code and html files
+// that live next to them.
+func (e *Event) Affects(url string) bool {
+	dir := path.Dir(url)
+	for _, p := range e.Paths {
+		if p == url || path.Dir(p) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	mu       sync.Mutex
+	handlers = make(map[string]func(*Event))
+)
+
+// Listen opens a connection to the server's event stream and dispatches every
+// event to the subscribed handlers. It does nothing if the browser doesn't
+// support server sent events.
+func Listen(url string) {
+	source := js.Global.Get("EventSource")
+	if source == js.Undefined {
+		return
+	}
+	es := source.New(url)
+	es.Set("onmessage", func(o *js.Object) {
+		e := &Event{}
+		if err := json.Unmarshal([]byte(o.Get("data").String()), e); err != nil {
+			println(err.Error())
+			return
+		}
+		mu.Lock()
+		var fns []func(*Event)
+		for _, fn := range handlers {
+			fns = append(fns, fn)
+		}
+		mu.Unlock()
+		for _, fn := range fns {
+			go fn(e)
+		}
+	})
+	es.Set("onerror", func(o *js.Object) {
+		// The server was not started in watch mode, there is nothing to
+		// listen to.
+		if es.Get("readyState").Int() == 2 {
+			es.Call("close")
+		}
+	})
+}
+
+// Subscribe registers fn to be called with every change event. Registering a
+// handler under an existing key replaces it.
+func Subscribe(key string, fn func(*Event)) {
+	mu.Lock()
+	handlers[key] = fn
+	mu.Unlock()
+}
+
+// Unsubscribe removes the handler registered with key.
+func Unsubscribe(key string) {
+	mu.Lock()
+	delete(handlers, key)
+	mu.Unlock()
+}
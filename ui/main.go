@@ -3,17 +3,18 @@ package main
 import (
 	"encoding/json"
 	"net/url"
-	"path/filepath"
+	"path"
 	"strings"
 	"sync"
 
 	"github.com/gernest/vectypresent/present/models"
 	"github.com/gernest/vectypresent/ui/article"
 	"github.com/gernest/vectypresent/ui/dir"
+	"github.com/gernest/vectypresent/ui/live"
 	"github.com/gernest/vectypresent/ui/router"
 	"github.com/gernest/vectypresent/ui/slide"
+	"github.com/gernest/vectypresent/ui/util"
 	"github.com/gernest/xhr"
-	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
 )
@@ -27,6 +28,15 @@ func main() {
 				if key != "/" && strings.HasSuffix(key, "/") {
 					key = strings.TrimSuffix(key, "/")
 				}
+				if key == "/tags" || strings.HasPrefix(key, "/tags/") {
+					tag, err := url.PathUnescape(strings.TrimPrefix(strings.TrimPrefix(key, "/tags"), "/"))
+					if err != nil {
+						tag = strings.TrimPrefix(key, "/tags/")
+					}
+					if root, ok := cache.Load("/"); ok {
+						return &dir.Tags{Tag: tag, Root: root.(*models.File), Router: r}
+					}
+				}
 				if vk, ok := cache.Load(key); ok {
 					val := vk.(*models.File)
 					switch {
@@ -47,37 +57,76 @@ func main() {
 		)
 	}
 	go func(done func()) {
-		data, err := xhr.Send("GET", "/context", nil)
-		if err != nil {
-			panic(err)
-		}
-		dir := &models.File{}
-		err = json.Unmarshal(data, dir)
-		if err != nil {
+		if err := loadContext(cache); err != nil {
 			panic(err)
 		}
-		cache.Store("/", dir)
-		dir.Cache(cache)
 		done()
 	}(r.BeforeRendering())
+	live.Subscribe("context", func(e *live.Event) {
+		if !e.Tree && !e.Meta {
+			return
+		}
+		if err := loadContext(cache); err != nil {
+			println(err.Error())
+			return
+		}
+		if e.Tree || showsListing(cache) {
+			// Only listings show the headers, re rendering an open document
+			// would reload it.
+			vecty.Rerender(r)
+		}
+	})
+	if !util.IsStatic() {
+		live.Listen(util.Path("/events"))
+	}
 	vecty.RenderBody(r)
 }
 
+// loadContext fetches the directory tree from the server and stores every
+// file in cache, dropping files that no longer exist.
+func loadContext(cache *sync.Map) error {
+	data, err := xhr.Send("GET", util.Path("/context"), nil)
+	if err != nil {
+		return err
+	}
+	dir := &models.File{}
+	err = json.Unmarshal(data, dir)
+	if err != nil {
+		return err
+	}
+	fresh := &sync.Map{}
+	dir.Cache(fresh)
+	cache.Range(func(k, v interface{}) bool {
+		if _, ok := fresh.Load(k); !ok {
+			cache.Delete(k)
+		}
+		return true
+	})
+	cache.Store("/", dir)
+	dir.Cache(cache)
+	return nil
+}
+
+// showsListing returns true if the current page lists documents, a directory or
+// a tag page.
+func showsListing(cache *sync.Map) bool {
+	route := util.Location()
+	if route == "/tags" || strings.HasPrefix(route, "/tags/") {
+		return true
+	}
+	v, ok := cache.Load(route)
+	return ok && v.(*models.File).IsDir
+}
+
 type PlainText struct {
 	vecty.Core
 	txt string
 }
 
 func (p *PlainText) Mount() {
-	location := js.Global.Get("location")
-	href := location.Get("href").String()
-	u, err := url.Parse(href)
-	if err != nil {
-		panic(err)
-	}
-	u.Path = filepath.Join("/files", u.Path)
+	u := util.Path(path.Join("/files", util.Location()))
 	go func() {
-		data, err := xhr.Send("GET", u.String(), nil)
+		data, err := xhr.Send("GET", u, nil)
 		if err != nil {
 			panic(err)
 		}
@@ -96,8 +145,8 @@ func (p *PlainText) Render() vecty.ComponentOrHTML {
 				elem.Preformatted(
 					vecty.Markup(
 						vecty.Style("text-align", "initial"),
-						vecty.UnsafeHTML(p.txt),
 					),
+					vecty.Text(p.txt),
 				),
 			),
 		),
@@ -1,8 +1,11 @@
 package router
 
 import (
+	"strings"
+
 	"github.com/gernest/locstor"
 	"github.com/gernest/vectypresent/ui/components"
+	"github.com/gernest/vectypresent/ui/util"
 	"github.com/gopherjs/gopherjs/js"
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
@@ -50,16 +53,21 @@ func (r *Router) BeforeRendering() func() {
 func (r *Router) Mount() {
 	js.Global.Set("onpopstate", func() {
 		go func() {
-			path := js.Global.Get("location").Get("pathname").String()
-			r.active = path
+			r.active = util.Location()
 			vecty.Rerender(r)
 		}()
 	})
 }
 
-// PushState re renders component registered on path.
+// PushState re renders component registered on path. The path is the route of
+// the file, the browser location is updated to the page rendering it. A
+// #fragment in path is kept in the location, it is not part of the route.
 func (r *Router) PushState(path string, ctx ...interface{}) {
-	js.Global.Get("history").Call("pushState", nil, "", path)
+	fragment := ""
+	if i := strings.IndexByte(path, '#'); i >= 0 {
+		path, fragment = path[:i], path[i:]
+	}
+	js.Global.Get("history").Call("pushState", nil, "", util.Page(path)+fragment)
 	r.active = path
 	r.context = ctx
 	locstor.SetItem(ActiveRoute, path)
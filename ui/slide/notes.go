@@ -0,0 +1,197 @@
+package slide
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/components"
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+const notesSheet = "/static/notes.css"
+
+// isPresenter returns true if the page was opened as the presenter view, that
+// is with a ?notes query.
+func isPresenter() bool {
+	_, ok := query()["notes"]
+	return ok
+}
+
+// query returns the parameters in the query of the page url.
+func query() url.Values {
+	search := js.Global.Get("location").Get("search").String()
+	q, _ := url.ParseQuery(strings.TrimPrefix(search, "?"))
+	return q
+}
+
+// queryParam returns the value of the query parameter name of the page url.
+func queryParam(name string) string {
+	return query().Get(name)
+}
+
+// syncKey is the local storage key holding the active slide of the deck at
+// page. Every window showing the deck listens for changes to it.
+func syncKey(page string) string {
+	return "vectypresent.slide:" + page
+}
+
+// listenSync follows the active slide changes made by other windows showing the
+// same deck, such as the presenter view and the audience window.
+func (s *Slide) listenSync(page string) {
+	key := syncKey(page)
+	s.onStorage = js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		e := args[0]
+		if e.Get("key").String() != key || e.Get("newValue") == nil {
+			return nil
+		}
+		n, err := strconv.Atoi(e.Get("newValue").String())
+		if err != nil || n == s.activeSlide || s.doc == nil {
+			return nil
+		}
+		go s.showSlide(n)
+		return nil
+	})
+	js.Global.Call("addEventListener", "storage", s.onStorage)
+	if s.presenter {
+		// Start where the audience window is.
+		if v := js.Global.Get("localStorage").Call("getItem", key); v != nil {
+			if n, err := strconv.Atoi(v.String()); err == nil {
+				s.activeSlide = n
+			}
+		}
+	}
+}
+
+func (s *Slide) stopSync() {
+	if s.onStorage != nil {
+		js.Global.Call("removeEventListener", "storage", s.onStorage)
+		s.onStorage = nil
+	}
+}
+
+// sync publishes the active slide to the other windows.
+func (s *Slide) sync() {
+	js.Global.Get("localStorage").Call("setItem",
+		syncKey(util.Location()), strconv.Itoa(s.activeSlide))
+}
+
+// toggleNotes opens the presenter view in a new window, or closes it if it is
+// already open.
+func (s *Slide) toggleNotes() {
+	if s.presenter {
+		return
+	}
+	if s.notesWindow != nil && !s.notesWindow.Get("closed").Bool() {
+		s.notesWindow.Call("close")
+		s.notesWindow = nil
+		return
+	}
+	s.sync()
+	u := util.Page(util.Location()) + "?notes"
+	s.notesWindow = js.Global.Call("open", u, "", "width=1000,height=700")
+}
+
+// startClock re renders the presenter view every second so the clock and the
+// elapsed time stay current.
+func (s *Slide) startClock() {
+	s.opened = time.Now()
+	s.clock = make(chan struct{})
+	go func(done chan struct{}) {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+				vecty.Rerender(s)
+			}
+		}
+	}(s.clock)
+}
+
+func (s *Slide) stopClock() {
+	if s.clock != nil {
+		close(s.clock)
+		s.clock = nil
+	}
+}
+
+// notes returns the notes of slide n, the title slide being 0.
+func (s *Slide) notes(n int) []string {
+	if n == 0 {
+		return s.doc.TitleNotes
+	}
+	if n <= len(s.doc.Sections) {
+		return s.doc.Sections[n-1].Notes
+	}
+	return nil
+}
+
+// renderSlide renders slide n alone, as the current slide.
+func (s *Slide) renderSlide(n int) vecty.ComponentOrHTML {
+	var slide vecty.ComponentOrHTML
+	switch {
+	case n == 0:
+		slide = s.titleSlide(components.Current)
+	case n <= len(s.doc.Sections):
+		slide = &components.Section{
+			S: s.doc.Sections[n-1], Pos: components.Current, Slide: true,
+			CancelTouch: true,
+		}
+	default:
+		return elem.Div(vecty.Markup(vecty.Class("presenter-end")), vecty.Text("End"))
+	}
+	return elem.Section(
+		vecty.Markup(vecty.Class("slides", "layout-widescreen", s.doc.ThemeClass())),
+		slide,
+	)
+}
+
+// renderPresenter renders the presenter view: the current and next slides,
+// the notes of the current slide and a clock.
+func (s *Slide) renderPresenter() vecty.ComponentOrHTML {
+	var notes vecty.List
+	for _, n := range s.notes(s.activeSlide) {
+		notes = append(notes, elem.Paragraph(
+			vecty.Markup(vecty.UnsafeHTML(string(models.Style(n)))),
+		))
+	}
+	elapsed := time.Since(s.opened).Truncate(time.Second)
+	return elem.Body(
+		vecty.Markup(
+			event.KeyDown(func(e *vecty.Event) {
+				s.KeyPress(e.Get("code").String())
+			}),
+		),
+		elem.Div(
+			vecty.Markup(vecty.Class("presenter-view")),
+			elem.Div(
+				vecty.Markup(vecty.Class("presenter-current")),
+				s.renderSlide(s.activeSlide),
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("presenter-next")),
+				s.renderSlide(s.activeSlide+1),
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("presenter-notes")),
+				notes,
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("presenter-clock")),
+				elem.Span(vecty.Text(time.Now().Format("15:04:05"))),
+				elem.Span(vecty.Text(elapsed.String())),
+				elem.Span(vecty.Text(fmt.Sprintf("%d / %d", s.activeSlide, len(s.doc.Sections)))),
+			),
+		),
+	)
+}
@@ -0,0 +1,113 @@
+package slide
+
+import (
+	"strconv"
+
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+	"github.com/gopherjs/vecty/event"
+)
+
+// overviewColumns is the number of thumbnails in a row of the overview, used to
+// move the selection up and down.
+const overviewColumns = 4
+
+// toggleOverview shows or hides the overview of all the slides. The current
+// slide is selected when it opens.
+func (s *Slide) toggleOverview() {
+	s.overview = !s.overview
+	s.selected = s.activeSlide
+	vecty.Rerender(s)
+	s.revealSelected()
+}
+
+// overviewKey handles key presses while the overview is shown.
+func (s *Slide) overviewKey(key string) {
+	n := s.selected
+	switch key {
+	case "ArrowRight":
+		n++
+	case "ArrowLeft":
+		n--
+	case "ArrowDown":
+		n += overviewColumns
+	case "ArrowUp":
+		n -= overviewColumns
+	case "Enter", "Space":
+		s.pick(s.selected)
+		return
+	case "KeyO", "Escape":
+		s.toggleOverview()
+		return
+	default:
+		return
+	}
+	if n < 0 || n > len(s.doc.Sections) {
+		return
+	}
+	s.selected = n
+	vecty.Rerender(s)
+	s.revealSelected()
+}
+
+// pick leaves the overview showing slide n.
+func (s *Slide) pick(n int) {
+	s.overview = false
+	s.showSlide(n)
+}
+
+// revealSelected scrolls the selected thumbnail into view.
+func (s *Slide) revealSelected() {
+	el := js.Global.Get("document").Call("querySelector", ".overview-item.selected")
+	if el != nil && el.Get("scrollIntoView") != js.Undefined {
+		el.Call("scrollIntoView", map[string]interface{}{"block": "nearest"})
+	}
+}
+
+// renderOverview renders every slide as a thumbnail with its number and title.
+func (s *Slide) renderOverview() vecty.ComponentOrHTML {
+	var items vecty.List
+	for i := 0; i <= len(s.doc.Sections); i++ {
+		n := i
+		title := s.doc.Title
+		if n > 0 {
+			title = s.doc.Sections[n-1].Title
+		}
+		items = append(items, elem.Div(
+			vecty.Markup(
+				vecty.Class("overview-item"),
+				vecty.ClassMap{
+					"current":  n == s.activeSlide,
+					"selected": n == s.selected,
+				},
+				event.Click(func(*vecty.Event) {
+					s.pick(n)
+				}),
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("overview-thumb")),
+				s.renderSlide(n),
+			),
+			elem.Div(
+				vecty.Markup(vecty.Class("overview-title")),
+				elem.Span(
+					vecty.Markup(vecty.Class("overview-number")),
+					vecty.Text(strconv.Itoa(n)),
+				),
+				vecty.Text(title),
+			),
+		))
+	}
+	return elem.Body(
+		vecty.Markup(
+			event.KeyDown(func(e *vecty.Event) {
+				s.KeyPress(e.Get("code").String())
+			}),
+		),
+		elem.Div(
+			vecty.Markup(vecty.Class("overview")),
+			items,
+		),
+	)
+}
@@ -0,0 +1,128 @@
+package slide
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/gernest/vectypresent/ui/util"
+	"github.com/gernest/xhr"
+	"github.com/gopherjs/gopherjs/js"
+	"github.com/gopherjs/vecty"
+	"github.com/gopherjs/vecty/elem"
+)
+
+// sessionMessage mirrors the messages exchanged with the server's session hub.
+type sessionMessage struct {
+	Type  string `json:"type"`
+	Deck  string `json:"deck,omitempty"`
+	Slide int    `json:"slide"`
+}
+
+// toggleSession starts a remote session for the deck and shows its code, so
+// other devices can join it. Once started the code is shown or hidden.
+func (s *Slide) toggleSession() {
+	if s.presenter || util.IsStatic() {
+		return
+	}
+	if s.sessionCode != "" {
+		s.showCode = !s.showCode
+		vecty.Rerender(s)
+		return
+	}
+	go func() {
+		u := util.Path("/remote/session?deck=" + url.QueryEscape(util.Location()))
+		data, err := xhr.Send("POST", u, nil)
+		if err != nil {
+			println("remote sessions are disabled, start the server with --remote")
+			return
+		}
+		var created struct {
+			Code string `json:"code"`
+		}
+		if err := json.Unmarshal(data, &created); err != nil {
+			println(err.Error())
+			return
+		}
+		s.joinSession(created.Code)
+		s.showCode = true
+		vecty.Rerender(s)
+	}()
+}
+
+// joinSession connects to the remote session with code. Slide changes made on
+// other devices are applied and local changes are sent to them.
+func (s *Slide) joinSession(code string) {
+	location := js.Global.Get("location")
+	scheme := "ws:"
+	if location.Get("protocol").String() == "https:" {
+		scheme = "wss:"
+	}
+	u := scheme + "//" + location.Get("host").String() +
+		util.Path("/remote/ws?code="+url.QueryEscape(code))
+	ws := js.Global.Get("WebSocket").New(u)
+	ws.Set("onmessage", func(o *js.Object) {
+		m := &sessionMessage{}
+		if err := json.Unmarshal([]byte(o.Get("data").String()), m); err != nil {
+			println(err.Error())
+			return
+		}
+		switch m.Type {
+		case "state", "slide":
+			go s.applySession(m.Slide)
+		}
+	})
+	ws.Set("onclose", func(*js.Object) {
+		if s.session == ws {
+			s.session = nil
+		}
+	})
+	s.session = ws
+	s.sessionCode = code
+}
+
+// applySession moves to slide n, selected on another device. The change is
+// not sent back to the session.
+func (s *Slide) applySession(n int) {
+	if s.doc == nil {
+		// Clamped once the document is loaded.
+		s.activeSlide = n
+		return
+	}
+	if n == s.activeSlide {
+		return
+	}
+	s.setSlide(n)
+	s.sync()
+	vecty.Rerender(s)
+}
+
+// sendSession sends the active slide to the other devices in the session.
+func (s *Slide) sendSession() {
+	if s.session == nil || s.session.Get("readyState").Int() != 1 {
+		return
+	}
+	b, err := json.Marshal(&sessionMessage{Type: "slide", Slide: s.activeSlide})
+	if err != nil {
+		return
+	}
+	s.session.Call("send", string(b))
+}
+
+func (s *Slide) leaveSession() {
+	if s.session != nil {
+		s.session.Call("close")
+		s.session = nil
+	}
+}
+
+// renderSessionCode renders the code and link other devices use to join the
+// session.
+func (s *Slide) renderSessionCode() vecty.ComponentOrHTML {
+	link := js.Global.Get("location").Get("origin").String() +
+		util.Path("/remote/"+s.sessionCode)
+	return elem.Div(
+		vecty.Markup(vecty.Class("session-code")),
+		elem.Strong(vecty.Text(s.sessionCode)),
+		elem.Paragraph(vecty.Text(link)),
+	)
+}
@@ -1,11 +1,10 @@
 package slide
 
 import (
-	"bytes"
 	"fmt"
 	"math"
-	"net/url"
-	"path/filepath"
+	"path"
+	"strconv"
 	"time"
 
 	"github.com/gopherjs/gopherjs/js"
@@ -13,8 +12,8 @@ import (
 
 	"github.com/gernest/vectypresent/present/models"
 	"github.com/gernest/vectypresent/ui/components"
+	"github.com/gernest/vectypresent/ui/live"
 	"github.com/gernest/vectypresent/ui/util"
-	"github.com/gernest/xhr"
 	"github.com/gopherjs/vecty"
 	"github.com/gopherjs/vecty/elem"
 )
@@ -31,6 +30,30 @@ type Slide struct {
 	auto        bool
 	startTime   time.Time
 	scale       string
+	url         string
+	err         error
+
+	// onLoad is called once the document is loaded.
+	onLoad     func()
+	onPopState *js.Object
+
+	// overview shows all the slides as thumbnails, selected is the
+	// highlighted one.
+	overview bool
+	selected int
+
+	// presenter is true in the presenter view, which shows the notes.
+	presenter   bool
+	notesWindow *js.Object
+	onStorage   *js.Object
+	opened      time.Time
+	clock       chan struct{}
+
+	// session is the WebSocket of the remote session the deck takes part
+	// in, if any.
+	session     *js.Object
+	sessionCode string
+	showCode    bool
 
 	touch struct {
 		dx, dy           float64
@@ -44,32 +67,56 @@ const (
 
 func (s *Slide) Mount() {
 	location := js.Global.Get("location")
+	s.presenter = isPresenter()
 	addStyle(location.Get("origin").String())
-	href := location.Get("href").String()
-	u, err := url.Parse(href)
-	if err != nil {
-		panic(err)
-	}
+	page := util.Location()
 	s.remote = &RemoteControl{
 		events: make(map[int]TickEvent),
 	}
-	u.Path = filepath.Join("/files", u.Path)
-	go func() {
-		data, err := xhr.Send("GET", u.String(), nil)
-		if err != nil {
-			panic(err)
-		}
-		doc := &models.Doc{}
-		err = models.Decode(bytes.NewReader(data), &doc)
-		if err != nil {
-			panic(err)
+	if n, ok := util.SlideNumber(); ok {
+		s.activeSlide = n
+	}
+	s.listenPopState(page)
+	s.listenSync(page)
+	if s.presenter {
+		s.startClock()
+	}
+	if code := queryParam("session"); code != "" {
+		s.joinSession(code)
+	}
+	if n, err := strconv.Atoi(queryParam("replay")); err == nil {
+		go s.replay(n)
+	}
+	s.url = util.Path(path.Join("/files", page))
+	live.Subscribe(s.url, func(e *live.Event) {
+		if e.Affects(page) {
+			s.fetch()
 		}
-		s.doc = doc
-		vecty.SetTitle(doc.Title)
-		s.scale = fmt.Sprintf("transform :%s;", ScaleSmallViewports())
-		vecty.Rerender(s)
-	}()
+	})
+	go s.fetch()
+}
 
+// fetch loads the document from the server and re renders the slides. The
+// active slide is kept so reloading an edited deck doesn't lose the position.
+func (s *Slide) fetch() {
+	doc, err := util.GetDoc(s.url)
+	if err != nil {
+		s.err = err
+		vecty.Rerender(s)
+		return
+	}
+	s.err = nil
+	s.doc = doc
+	if s.activeSlide > len(doc.Sections) {
+		s.activeSlide = len(doc.Sections)
+	}
+	vecty.SetTitle(doc.Title)
+	s.scale = fmt.Sprintf("transform :%s;", ScaleSmallViewports())
+	vecty.Rerender(s)
+	if f := s.onLoad; f != nil {
+		s.onLoad = nil
+		f()
+	}
 }
 
 func ScaleSmallViewports() string {
@@ -88,39 +135,48 @@ func ScaleSmallViewports() string {
 	return transform
 }
 func (s *Slide) Unmount() {
+	live.Unsubscribe(s.url)
+	s.stopSync()
+	s.stopPopState()
+	s.stopClock()
+	s.leaveSession()
 	restoreStyle(js.Global.Get("location").Get("origin").String())
 }
 
-// disable all other stylesheets and only leave styles.css
+// disable all other stylesheets and only leave styles.css and notes.css
 func addStyle(origin string) {
-	slideHref := origin + slideSheet
-	hasSlideSheet := false
+	keep := map[string]bool{
+		origin + util.Path(slideSheet): false,
+		origin + util.Path(notesSheet): false,
+	}
 	util.ListSheets(func(sheet *js.Object) bool {
 		href := sheet.Get("href").String()
-		switch href {
-		case slideHref:
+		if _, ok := keep[href]; ok {
 			sheet.Set("disabled", false)
-			hasSlideSheet = true
-		default:
+			keep[href] = true
+		} else {
 			sheet.Set("disabled", true)
 		}
 		return true
 	})
 
-	if !hasSlideSheet {
-		link := js.Global.Get("document").Call("createElement", "link")
-		link.Set("rel", "stylesheet")
-		link.Set("href", slideHref)
-		js.Global.Get("document").Get("head").Call("appendChild", link)
+	for href, found := range keep {
+		if !found {
+			link := js.Global.Get("document").Call("createElement", "link")
+			link.Set("rel", "stylesheet")
+			link.Set("href", href)
+			js.Global.Get("document").Get("head").Call("appendChild", link)
+		}
 	}
 }
 
 func restoreStyle(origin string) {
-	slideHref := origin + slideSheet
+	slideHref := origin + util.Path(slideSheet)
+	notesHref := origin + util.Path(notesSheet)
 	util.ListSheets(func(sheet *js.Object) bool {
 		href := sheet.Get("href").String()
 		switch href {
-		case slideHref:
+		case slideHref, notesHref:
 			sheet.Set("disabled", true)
 		default:
 			sheet.Set("disabled", false)
@@ -147,9 +203,18 @@ func getPos(active, n int) components.Position {
 }
 
 func (s *Slide) Render() vecty.ComponentOrHTML {
+	if s.err != nil {
+		return &components.ErrorPage{Err: s.err}
+	}
 	if s.doc == nil {
 		return elem.Body()
 	}
+	if s.presenter {
+		return s.renderPresenter()
+	}
+	if s.overview {
+		return s.renderOverview()
+	}
 	var sections vecty.List
 	for i, section := range s.doc.Sections {
 		pos := getPos(s.activeSlide, i+1)
@@ -161,14 +226,6 @@ func (s *Slide) Render() vecty.ComponentOrHTML {
 				OnTouchMove:  s.handleTouchMove,
 			})
 	}
-	var authors vecty.List
-	for _, author := range s.doc.Authors {
-		authors = append(authors, elem.Div(
-			vecty.Markup(vecty.Class("presenter")),
-			components.RenderElems(author.Elem),
-		))
-	}
-	initPos := getPos(s.activeSlide, 0)
 	return elem.Body(
 		vecty.Markup(
 			vecty.Style("display", "none"),
@@ -179,31 +236,45 @@ func (s *Slide) Render() vecty.ComponentOrHTML {
 		),
 		elem.Section(
 			vecty.Markup(
-				vecty.Class("slides", "layout-widescreen"),
+				vecty.Class("slides", "layout-widescreen", s.doc.ThemeClass()),
 				vecty.Attribute("style", s.scale),
 			),
-			elem.Article(
-				vecty.Markup(
-					vecty.MarkupIf(initPos.Class() != "",
-						vecty.Class(initPos.Class()),
-					),
-					event.TouchStart(s.handleTouchStart),
-					event.TouchEnd(s.handleTouchEnd),
-					event.TouchMove(s.handleTouchMove),
-				),
-				elem.Heading1(
-					vecty.Text(s.doc.Title),
-				),
-				vecty.If(s.doc.Subtitle != "", elem.Heading3(
-					vecty.Text(s.doc.Subtitle),
-				)),
-				vecty.If(!s.doc.Time.IsZero(), elem.Heading3(
-					vecty.Text(s.doc.Time.Format(models.TimeFormat)),
-				)),
-				authors,
-			),
+			s.titleSlide(getPos(s.activeSlide, 0)),
 			sections,
 		),
+		vecty.If(s.showCode && s.sessionCode != "", s.renderSessionCode()),
+	)
+}
+
+// titleSlide renders the first slide, made of the document header and the
+// authors, at pos.
+func (s *Slide) titleSlide(pos components.Position) *vecty.HTML {
+	var authors vecty.List
+	for _, author := range s.doc.Authors {
+		authors = append(authors, elem.Div(
+			vecty.Markup(vecty.Class("presenter")),
+			components.RenderElems(author.Elem),
+		))
+	}
+	return elem.Article(
+		vecty.Markup(
+			vecty.MarkupIf(pos.Class() != "",
+				vecty.Class(pos.Class()),
+			),
+			event.TouchStart(s.handleTouchStart),
+			event.TouchEnd(s.handleTouchEnd),
+			event.TouchMove(s.handleTouchMove),
+		),
+		elem.Heading1(
+			vecty.Text(s.doc.Title),
+		),
+		vecty.If(s.doc.Subtitle != "", elem.Heading3(
+			vecty.Text(s.doc.Subtitle),
+		)),
+		vecty.If(!s.doc.Time.IsZero(), elem.Heading3(
+			vecty.Text(s.doc.Time.Format(models.TimeFormat)),
+		)),
+		authors,
 	)
 }
 
@@ -243,7 +314,17 @@ func (s *Slide) handleTouchMove(e *vecty.Event) {
 	}
 }
 
+// showSlide moves to slide n and tells the other windows and devices showing
+// the deck.
 func (s *Slide) showSlide(n int) {
+	s.setSlide(n)
+	s.sync()
+	s.sendSession()
+	vecty.Rerender(s)
+}
+
+// setSlide makes n the active slide and reflects it in the page url.
+func (s *Slide) setSlide(n int) {
 	if n < 0 {
 		s.activeSlide = 0
 	} else if n > len(s.doc.Sections) {
@@ -251,7 +332,31 @@ func (s *Slide) showSlide(n int) {
 	} else {
 		s.activeSlide = n
 	}
-	vecty.Rerender(s)
+	u := util.SlideURL(util.Location(), s.activeSlide)
+	js.Global.Get("history").Call("replaceState", nil, "", u)
+}
+
+// listenPopState restores the slide linked to by the url when the browser
+// navigates to the deck, or the fragment is edited.
+func (s *Slide) listenPopState(page string) {
+	s.onPopState = js.MakeFunc(func(this *js.Object, args []*js.Object) interface{} {
+		if util.Location() != page || s.doc == nil {
+			return nil
+		}
+		n, _ := util.SlideNumber()
+		if n != s.activeSlide {
+			go s.showSlide(n)
+		}
+		return nil
+	})
+	js.Global.Call("addEventListener", "popstate", s.onPopState)
+}
+
+func (s *Slide) stopPopState() {
+	if s.onPopState != nil {
+		js.Global.Call("removeEventListener", "popstate", s.onPopState)
+		s.onPopState = nil
+	}
 }
 
 func (s *Slide) next() {
@@ -263,6 +368,13 @@ func (s *Slide) prev() {
 }
 
 func (s *Slide) KeyPress(key string) {
+	if s.doc == nil {
+		return
+	}
+	if s.overview {
+		s.overviewKey(key)
+		return
+	}
 	up := false
 	switch key {
 	case "ArrowRight", "ArrowUp":
@@ -273,9 +385,11 @@ func (s *Slide) KeyPress(key string) {
 		if !s.recording {
 			s.recording = true
 			s.startTime = time.Now()
+			s.remote.events = make(map[int]TickEvent)
 		} else {
 			s.remote.length = time.Now().Sub(s.startTime)
 			s.recording = false
+			go s.saveRecording()
 		}
 		up = true
 	case "Space":
@@ -283,9 +397,14 @@ func (s *Slide) KeyPress(key string) {
 			s.remote.Add(s.activeSlide+1, time.Now().Sub(s.startTime))
 		}
 		if s.activeSlide < len(s.doc.Sections) {
-			s.activeSlide++
-			up = true
+			s.next()
 		}
+	case "KeyN":
+		s.toggleNotes()
+	case "KeyO":
+		s.toggleOverview()
+	case "KeyS":
+		s.toggleSession()
 	case "KeyP":
 		if !s.auto {
 			s.auto = true
@@ -0,0 +1,88 @@
+package slide
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/gernest/vectypresent/present/models"
+	"github.com/gernest/vectypresent/ui/util"
+)
+
+// Recording returns the recorded slide changes in the timing file format.
+func (r *RemoteControl) Recording() models.Recording {
+	rec := models.Recording{
+		Length: int64(r.length / time.Millisecond),
+		Ticks:  []models.Tick{},
+	}
+	for _, e := range r.events {
+		rec.Ticks = append(rec.Ticks, models.Tick{
+			At:    int64(e.Time / time.Millisecond),
+			Slide: e.Slide,
+		})
+	}
+	sort.Slice(rec.Ticks, func(i, j int) bool {
+		return rec.Ticks[i].At < rec.Ticks[j].At
+	})
+	return rec
+}
+
+// Load replaces the recorded slide changes with rec.
+func (r *RemoteControl) Load(rec models.Recording) {
+	r.length = time.Duration(rec.Length) * time.Millisecond
+	r.events = make(map[int]TickEvent)
+	for _, t := range rec.Ticks {
+		r.Add(t.Slide, time.Duration(t.At)*time.Millisecond)
+	}
+}
+
+// saveRecording stores the recording on the server, next to the deck.
+func (s *Slide) saveRecording() {
+	if util.IsStatic() {
+		return
+	}
+	b, err := json.Marshal(s.remote.Recording())
+	if err != nil {
+		println(err.Error())
+		return
+	}
+	status, data, err := util.Post(util.Path("/timings"+util.Location()), b)
+	switch {
+	case err != nil:
+		println("saving the recording: " + err.Error())
+	case status != 201:
+		println("saving the recording: " + string(data))
+	}
+}
+
+// replay plays the recording with index n saved for the deck, once the deck is
+// loaded.
+func (s *Slide) replay(n int) {
+	status, data, err := util.Get(util.Path("/timings" + util.Location()))
+	if err != nil || status != 200 {
+		println(fmt.Sprintf("loading the recordings: status %d %v", status, err))
+		return
+	}
+	t := &models.Timing{}
+	if err := json.Unmarshal(data, t); err != nil {
+		println(err.Error())
+		return
+	}
+	if n < 0 || n >= len(t.Recordings) {
+		println(fmt.Sprintf("recording %d doesn't exist", n))
+		return
+	}
+	s.remote.Load(t.Recordings[n])
+	start := func() {
+		if !s.auto {
+			s.auto = true
+			s.play()
+		}
+	}
+	if s.doc == nil {
+		s.onLoad = start
+		return
+	}
+	start()
+}
@@ -1,6 +1,15 @@
 package util
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gernest/vectypresent/present/models"
 	"github.com/gopherjs/gopherjs/js"
 )
 
@@ -14,3 +23,202 @@ func ListSheets(f func(*js.Object) bool) {
 		}
 	}
 }
+
+var base string
+
+// Base returns the absolute path under which the app is mounted. It always
+// ends with a slash.
+//
+// The server sets the base in the VECTYPRESENT global of the index page. The
+// base in exported sites is relative to the page, so it is resolved against the
+// location the app was loaded from.
+func Base() string {
+	if base == "" {
+		b := "/"
+		if cfg := js.Global.Get("VECTYPRESENT"); cfg != js.Undefined {
+			b = cfg.Get("base").String()
+		}
+		href := js.Global.Get("location").Get("href")
+		base = js.Global.Get("URL").New(b, href).Get("pathname").String()
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+	}
+	return base
+}
+
+// IsStatic returns true if the app is running from an exported static site.
+func IsStatic() bool {
+	cfg := js.Global.Get("VECTYPRESENT")
+	return cfg != js.Undefined && cfg.Get("static").Bool()
+}
+
+// Path returns the absolute path for the server path p, taking the base into
+// account.
+func Path(p string) string {
+	return Base() + strings.TrimPrefix(p, "/")
+}
+
+// Page returns the browser path of the page rendering the file at route.
+// Exported sites store pages as html files next to the documents.
+func Page(route string) string {
+	if IsStatic() {
+		switch path.Ext(route) {
+		case ".slide", ".article":
+			route += ".html"
+		case "":
+			route = path.Join(route, "index.html")
+		}
+	}
+	return Path(route)
+}
+
+// Route is the inverse of Page, it returns the route of the file rendered by
+// the page at the browser path p.
+func Route(p string) string {
+	p = "/" + strings.TrimPrefix(p, Base())
+	if IsStatic() {
+		p = strings.TrimSuffix(p, "index.html")
+		p = strings.TrimSuffix(p, ".html")
+	}
+	if p != "/" {
+		p = strings.TrimSuffix(p, "/")
+	}
+	if deck, _, ok := splitSlide(p); ok {
+		p = deck
+	}
+	return p
+}
+
+// splitSlide splits the path of a slide of a deck, like /talks/intro.slide/12,
+// in the path of the deck and the slide number.
+func splitSlide(p string) (deck string, n int, ok bool) {
+	deck, num := path.Split(p)
+	deck = strings.TrimSuffix(deck, "/")
+	if path.Ext(deck) != ".slide" {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 0 {
+		return "", 0, false
+	}
+	return deck, n, true
+}
+
+// SlideNumber returns the slide linked to by the page url, given either as a
+// #12 fragment or as a /talks/intro.slide/12 path.
+func SlideNumber() (int, bool) {
+	location := js.Global.Get("location")
+	hash := strings.TrimPrefix(location.Get("hash").String(), "#")
+	if n, err := strconv.Atoi(hash); err == nil && n >= 0 {
+		return n, true
+	}
+	p := "/" + strings.TrimPrefix(location.Get("pathname").String(), Base())
+	if _, n, ok := splitSlide(p); ok {
+		return n, true
+	}
+	return 0, false
+}
+
+// SlideURL returns the url linking to slide n of the deck at route. The query
+// of the current page is kept, the title slide has no fragment.
+func SlideURL(route string, n int) string {
+	u := Page(route) + js.Global.Get("location").Get("search").String()
+	if n > 0 {
+		u += "#" + strconv.Itoa(n)
+	}
+	return u
+}
+
+// Location returns the route of the file rendered by the current page.
+func Location() string {
+	return Route(js.Global.Get("location").Get("pathname").String())
+}
+
+// Get fetches url and returns the response status code and body. Unlike xhr,
+// responses with error statuses are returned so their body can be read. It
+// blocks, so it must be called from a goroutine.
+func Get(url string) (int, []byte, error) {
+	return send("GET", url, nil)
+}
+
+// Post is like Get but posts body to url. The X-Requested-With header tells the
+// server the request comes from the frontend, pages of other origins can't set
+// it.
+func Post(url string, body []byte) (int, []byte, error) {
+	return send("POST", url, body)
+}
+
+func send(method, url string, body []byte) (int, []byte, error) {
+	req := js.Global.Get("XMLHttpRequest").New()
+	req.Call("open", method, url, true)
+	req.Set("responseType", "arraybuffer")
+	if method == "POST" {
+		req.Call("setRequestHeader", "X-Requested-With", "XMLHttpRequest")
+	}
+	done := make(chan error, 1)
+	req.Call("addEventListener", "load", func(*js.Object) {
+		done <- nil
+	})
+	req.Call("addEventListener", "error", func(*js.Object) {
+		done <- errors.New("network error fetching " + url)
+	})
+	if body == nil {
+		req.Call("send")
+	} else {
+		req.Call("send", js.NewArrayBuffer(body))
+	}
+	if err := <-done; err != nil {
+		return 0, nil, err
+	}
+	data := js.Global.Get("Uint8Array").New(req.Get("response")).Interface().([]byte)
+	return req.Get("status").Int(), data, nil
+}
+
+// prerendered is the document the server rendered in the initial page. It is
+// read before the frontend replaces the page body.
+var prerendered struct {
+	route string
+	data  []byte
+}
+
+func init() {
+	el := js.Global.Get("document").Call("getElementById", "vectypresent-doc")
+	if el == nil || el == js.Undefined {
+		return
+	}
+	prerendered.route = el.Call("getAttribute", "data-route").String()
+	prerendered.data = []byte(el.Get("textContent").String())
+}
+
+// GetDoc fetches the parsed document at url. Errors reported by the server are
+// returned as *models.Error.
+//
+// The first call for the document rendered by the server uses the copy
+// embedded in the page instead.
+func GetDoc(url string) (*models.Doc, error) {
+	if prerendered.data != nil && url == Path(path.Join("/files", prerendered.route)) {
+		data := prerendered.data
+		prerendered.data = nil
+		doc := &models.Doc{}
+		if err := models.Decode(bytes.NewReader(data), doc); err == nil {
+			return doc, nil
+		}
+	}
+	status, data, err := Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		e := &models.Error{}
+		if err := json.Unmarshal(data, e); err != nil {
+			return nil, fmt.Errorf("fetching %s: status %d", url, status)
+		}
+		return nil, e
+	}
+	doc := &models.Doc{}
+	if err := models.Decode(bytes.NewReader(data), doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}